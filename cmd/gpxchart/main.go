@@ -2,16 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/tkrajina/go-elevations/geoelevations"
 	"github.com/tkrajina/gpxchart/gpxcharts"
@@ -20,11 +22,30 @@ import (
 
 const OptsBackupExtension = ".gpxcharts_opts"
 
+const (
+	// ClimbsCommand prints the climbs instead of charting
+	ClimbsCommand = "climbs"
+	// SplitsCommand prints the splits instead of charting
+	SplitsCommand = "splits"
+	// ThumbnailsCommand charts sparklines for all GPX files in a directory
+	ThumbnailsCommand = "thumbnails"
+)
+
 type GraphType string
 
 const (
 	Elevation GraphType = "elevation"
 	Speed     GraphType = "speed"
+	Pace      GraphType = "pace"
+	VAM       GraphType = "vam"
+	Ascent    GraphType = "ascent"
+	Grades    GraphType = "grades"
+	Zones     GraphType = "zones"
+	Map       GraphType = "map"
+	Steepness GraphType = "steepness"
+	TimeGap   GraphType = "gap"
+	Splits    GraphType = "splits"
+	Extension GraphType = "ext:"
 )
 
 func panicIfErr(err error) {
@@ -45,6 +66,7 @@ func main() {
 	}
 
 	c := context.Background()
+	var err error
 	var (
 		params           gpxcharts.ChartParams
 		typ              string
@@ -59,6 +81,30 @@ func main() {
 		debug            bool
 		srtm             bool
 		smoothElevations bool
+		xAxisMode        string
+		timeZone         string
+		paceUnit         string
+		gradeBuckets     string
+		zonesFile        string
+		gradeColors      string
+		legendCorner     string
+		y2Type           string
+		source           string
+		sourceNo         int
+		jsonOutput       bool
+		statsFields      string
+		statsCorner      string
+		gapPolicy        string
+		stopSpeed        float64
+		csvOutput        bool
+		splitDistance    float64
+		splitMetric      string
+		thumbnailsExt    string
+		theme            string
+		fillGradient     string
+		fontFiles        string
+		fontStyles       string
+		fontSizes        string
 	)
 
 	flag.BoolVar(&help, "help", false, "Help")
@@ -68,14 +114,66 @@ func main() {
 	flag.StringVar(&padding, "p", "40,20,0,0", "Padding (left,down,right,up)")
 	flag.StringVar(&chartPadding, "cp", "20,5,20,10", "Chart padding (left,down,right,up)")
 	flag.StringVar(&fontSize, "f", "8,8", "Both axes font size (x,y)")
-	flag.StringVar(&typ, "t", string(Elevation), fmt.Sprintf("Type (%s or %s)", Elevation, Speed))
+	flag.StringVar(&fontSizes, "fs", "0,0", "Title and annotation (labels, legend, stats) font sizes (title,annotation), 0 for the size of X axis labels")
+	flag.StringVar(&fontFiles, "font", "", "Comma separated TTF font files (regular,bold,italic,bold-italic), default the embedded Luxi Sans")
+	flag.StringVar(&fontStyles, "fst", "regular,regular,regular", fmt.Sprintf("Font styles (%s) of axis labels, titles and annotations (axis,title,annotation)", joinFontStyles()))
+	flag.StringVar(&typ, "t", string(Elevation), fmt.Sprintf("Type (%s, %s, %s, %s, %s, %s, %s, %s, %s, %s, %s or %s<name>, for example %sgpxtpx:hr), or more comma separated types for stacked panels (for example %s,%s,%s)", Elevation, Speed, Pace, VAM, Steepness, Ascent, Grades, Zones, Map, TimeGap, Splits, Extension, Extension, Elevation, Speed, Steepness))
+	flag.StringVar(&xAxisMode, "x", string(gpxcharts.XAxisDistance), fmt.Sprintf("X axis (%s)", joinXAxisModes()))
+	flag.StringVar(&timeZone, "tz", "UTC", "Time zone for the clock time X axis (for example Europe/Zagreb)")
+	flag.StringVar(&paceUnit, "pu", "", fmt.Sprintf("Pace unit (%s), default by units", joinPaceUnits()))
+	flag.DurationVar(&params.VerticalSpeedWindow, "vw", 2*time.Minute, "Vertical speed (VAM) smoothing window")
+	flag.Float64Var(&params.AscentThreshold, "at", gpxcharts.DefaultAscentThreshold, "Cumulative ascent/descent threshold (in meters, negative for none)")
+	flag.StringVar(&gradeBuckets, "gb", "-10,-5,0,5,10,15", "Grade histogram buckets (in percent)")
+	flag.StringVar(&zonesFile, "zones", "", "Zones definition (JSON file) for the zones chart")
+	flag.StringVar(&y2Type, "y2", "", fmt.Sprintf("Second chart type on the right Y axis of the elevation chart (%s or %s<name>)", joinChartTypes(), Extension))
+	flag.BoolVar(&params.ColorByGrade, "cg", false, "Color elevation chart by grade")
+	flag.StringVar(&gradeColors, "gcs", "", "Grade colors and edges (color,grade,color,...,color), for example 30a030,3,f0d020,6,f09020,9,e05020,12,c01010")
+	flag.StringVar(&legendCorner, "lc", string(gpxcharts.CornerTopRight), fmt.Sprintf("Legend corner (%s)", joinCorners()))
+	flag.BoolVar(&params.ScaleBar, "sb", false, "Scale bar (map only)")
+	flag.BoolVar(&params.NorthArrow, "na", false, "North arrow (map only)")
+	flag.BoolVar(&params.Climbs, "cl", false, "Shade climbs on the elevation chart")
+	flag.BoolVar(&jsonOutput, "json", false, fmt.Sprintf("JSON output (%s and %s only)", ClimbsCommand, SplitsCommand))
+	flag.BoolVar(&csvOutput, "csv", false, fmt.Sprintf("CSV output (%s only)", SplitsCommand))
+	flag.Float64Var(&splitDistance, "split", 0, "Split distance (in km, or mi with -im), default 1")
+	flag.StringVar(&splitMetric, "splitm", string(gpxcharts.SplitPace), fmt.Sprintf("Splits chart bars (%s)", joinSplitMetrics()))
+	flag.BoolVar(&params.MinMaxMarkers, "mm", false, "Min/max elevation (or top speed) markers")
+	flag.StringVar(&statsFields, "st", "", fmt.Sprintf("Comma separated fields in the stats box (%s)", joinStatsFields()))
+	flag.StringVar(&statsCorner, "stc", string(gpxcharts.CornerTopLeft), fmt.Sprintf("Stats box corner (%s)", joinCorners()))
+	flag.StringVar(&gapPolicy, "gap", string(gpxcharts.GapConnect), fmt.Sprintf("Gaps between track segments (%s)", joinGapPolicies()))
+	flag.BoolVar(&params.GapDistance, "gd", false, "Count the distance between track segments")
+	flag.BoolVar(&params.Stops, "stop", false, "Shade stops")
+	flag.BoolVar(&params.CollapseStops, "stopc", false, fmt.Sprintf("Remove stops from the %s time X axis (only with -x %s)", gpxcharts.XAxisElapsedTime, gpxcharts.XAxisElapsedTime))
+	flag.Float64Var(&stopSpeed, "stopv", 1, "Stop speed threshold (in km/h)")
+	flag.DurationVar(&params.StopDuration, "stopd", gpxcharts.DefaultStopDuration, "Minimum stop duration")
+	flag.BoolVar(&params.Waypoints, "wpt", false, "Waypoints on the elevation chart")
+	flag.Float64Var(&params.MaxWaypointDistance, "wptd", 0, "Ignore waypoints farther from the track (in meters)")
+	flag.StringVar(&source, "src", "", fmt.Sprintf("Source (%s), default tracks or routes if there are no tracks", joinSources()))
+	flag.IntVar(&sourceNo, "srcn", 0, "Number of the track or route (starting with 1), default all")
 	flag.BoolVar(&imperial, "im", false, "Use imperial units (mi, ft)")
 	flag.BoolVar(&srtm, "srtm", false, "Overwrite elevations from SRTM")
 	flag.BoolVar(&smoothElevations, "sme", false, "Smooth elevations")
 	flag.BoolVar(&imperial, "d", false, "Debug")
+	flag.BoolVar(&params.Sparkline, "spark", false, fmt.Sprintf("Sparkline without axes, grid, labels and margins (always for %s)", ThumbnailsCommand))
+	flag.BoolVar(&params.SparklineMinMax, "sparkmm", false, "Min/max dots on sparklines")
+	flag.StringVar(&thumbnailsExt, "ext", string(gpxcharts.OutputPNG), fmt.Sprintf("Image extension (%s only)", ThumbnailsCommand))
+	flag.StringVar(&theme, "theme", string(gpxcharts.ThemeLight), fmt.Sprintf("Theme (%s) or a JSON theme file", joinThemeNames()))
+	flag.BoolVar(&params.TransparentBackground, "tr", false, "Transparent background")
+	flag.StringVar(&fillGradient, "fg", "", "Gradient fill colors (top,bottom), for example d04020d0,d0402010")
 	flag.Float64Var(&params.LineWidth, "lw", 0.5, "Line width")
 	flag.Parse()
 
+	command := flag.Arg(0)
+	if command == ClimbsCommand || command == SplitsCommand || command == ThumbnailsCommand {
+		// Options can be also after the command:
+		panicIfErr(flag.CommandLine.Parse(flag.Args()[1:]))
+	}
+	if command == ThumbnailsCommand {
+		params.Sparkline = true
+		if !isFlagSet("s") {
+			size = fmt.Sprintf("%d,%d", gpxcharts.SparklineWidth, gpxcharts.SparklineHeight)
+		}
+	}
+
 	if help {
 		showHelpAndExit(0)
 	}
@@ -83,6 +181,79 @@ func main() {
 	if imperial {
 		params.Unit = gpxcharts.UnitTypeImperial
 	}
+	params.SplitDistance = splitDistance * 1000
+	if imperial {
+		params.SplitDistance = splitDistance * gpxcharts.ONE_MILE
+	}
+	params.SplitMetric = gpxcharts.SplitMetric(splitMetric)
+	if !strings.Contains(", "+joinSplitMetrics()+", ", ", "+splitMetric+", ") {
+		showHelpAndExit(1)
+	}
+	params.StopSpeed = stopSpeed * 1000 / 3600
+	params.XAxisMode = gpxcharts.XAxisMode(xAxisMode)
+	if !strings.Contains(", "+joinXAxisModes()+", ", ", "+xAxisMode+", ") {
+		showHelpAndExit(1)
+	}
+	if params.CollapseStops && params.XAxisMode != gpxcharts.XAxisElapsedTime {
+		fmt.Printf("Stops can be removed only from the %s time X axis (-x %s)\n", gpxcharts.XAxisElapsedTime, gpxcharts.XAxisElapsedTime)
+		os.Exit(1)
+	}
+	params.TimeZone, err = time.LoadLocation(timeZone)
+	panicIfErr(err)
+	params.PaceUnit = gpxcharts.PaceUnit(paceUnit)
+	if paceUnit != "" && !strings.Contains(", "+joinPaceUnits()+", ", ", "+paceUnit+", ") {
+		showHelpAndExit(1)
+	}
+	params.GradeBuckets = parseFloats(gradeBuckets)
+	if gradeColors != "" {
+		params.ColorByGrade = true
+		params.GradeColors = parseGradeColors(gradeColors)
+	}
+	params.LegendCorner = gpxcharts.Corner(legendCorner)
+	if !strings.Contains(", "+joinCorners()+", ", ", "+legendCorner+", ") {
+		showHelpAndExit(1)
+	}
+	if statsFields != "" {
+		for _, field := range strings.Split(statsFields, ",") {
+			field = strings.TrimSpace(field)
+			if !strings.Contains(", "+joinStatsFields()+", ", ", "+field+", ") {
+				showHelpAndExit(1)
+			}
+			params.Stats = append(params.Stats, gpxcharts.StatsField(field))
+		}
+	}
+	params.StatsCorner = gpxcharts.Corner(statsCorner)
+	if !strings.Contains(", "+joinCorners()+", ", ", "+statsCorner+", ") {
+		showHelpAndExit(1)
+	}
+	if source != "" && !strings.Contains(", "+joinSources()+", ", ", "+source+", ") {
+		showHelpAndExit(1)
+	}
+	params.GapPolicy = gpxcharts.GapPolicy(gapPolicy)
+	if !strings.Contains(", "+joinGapPolicies()+", ", ", "+gapPolicy+", ") {
+		showHelpAndExit(1)
+	}
+	if strings.Contains(", "+joinThemeNames()+", ", ", "+theme+", ") {
+		params.Theme, err = gpxcharts.BuiltinTheme(gpxcharts.ThemeName(theme))
+		panicIfErr(err)
+	} else {
+		byts, err := ioutil.ReadFile(theme)
+		panicIfErr(err)
+		params.Theme, err = gpxcharts.ParseTheme(byts)
+		panicIfErr(err)
+	}
+	if fillGradient != "" {
+		parts := strings.Split(fillGradient, ",")
+		if len(parts) != 2 {
+			showHelpAndExit(1)
+		}
+		var gradient gpxcharts.Gradient
+		gradient.Top, err = gpxcharts.ParseHexColor(parts[0])
+		panicIfErr(err)
+		gradient.Bottom, err = gpxcharts.ParseHexColor(parts[1])
+		panicIfErr(err)
+		params.FillGradient = &gradient
+	}
 	params.Width, params.Height = twoInts(size)
 	params.XAxis.FontSize, params.YAxis.FontSize = twoFloats(fontSize)
 	params.XAxis.Grid, params.YAxis.Grid = twoFloats(grid)
@@ -93,10 +264,30 @@ func main() {
 		Top: 0, Right: 0, Bottom: 20, Left: 40,
 	}
 
-	cs, err := gpxcharts.NewChartService([]string{prepareFont()})
+	cs, err := gpxcharts.NewChartServiceWithFont(LuxiFont)
 	if err != nil {
 		panic(err)
 	}
+	if fontFiles != "" {
+		for n, fontFile := range strings.Split(fontFiles, ",") {
+			if n >= len(gpxcharts.AllFontStyles()) {
+				showHelpAndExit(1)
+			}
+			panicIfErr(cs.LoadFontFile(gpxcharts.DefaultFontFamily, gpxcharts.AllFontStyles()[n], strings.TrimSpace(fontFile)))
+		}
+	}
+	styles := strings.Split(fontStyles, ",")
+	if len(styles) != 3 {
+		showHelpAndExit(1)
+	}
+	for n, font := range []*gpxcharts.Font{&params.AxisFont, &params.TitleFont, &params.AnnotationFont} {
+		style := strings.TrimSpace(styles[n])
+		if !strings.Contains(", "+joinFontStyles()+", ", ", "+style+", ") {
+			showHelpAndExit(1)
+		}
+		font.Style = gpxcharts.FontStyle(style)
+	}
+	params.TitleFont.Size, params.AnnotationFont.Size = twoFloats(fontSizes)
 
 	params.ChartMargin.Left, params.ChartMargin.Bottom, params.ChartMargin.Right, params.ChartMargin.Top = fourFloats(padding)
 	params.ChartPadding.Left, params.ChartPadding.Bottom, params.ChartPadding.Right, params.ChartPadding.Top = fourFloats(chartPadding)
@@ -105,37 +296,140 @@ func main() {
 		fmt.Printf("params=%#v\n", params)
 	}
 
-	var chartGen func(c context.Context, params gpxcharts.ChartParams, g gpx.GPX, output gpxcharts.OutputExtension) ([]byte, error)
-	switch GraphType(typ) {
-	case Elevation:
-		chartGen = cs.ElevationChart
-	case Speed:
-		chartGen = cs.SpeedChart
+	var chartGen func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error)
+	switch {
+	case GraphType(typ) == Elevation && y2Type != "":
+		chartGen = func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error) {
+			return cs.DualAxisChart(c, params, g, gpxcharts.ChartType(y2Type), output)
+		}
+	case GraphType(typ) == Elevation:
+		chartGen = withoutExtensions(cs.ElevationChart)
+	case strings.Contains(typ, ","):
+		var types []gpxcharts.ChartType
+		for _, part := range strings.Split(typ, ",") {
+			types = append(types, gpxcharts.ChartType(strings.TrimSpace(part)))
+		}
+		chartGen = func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error) {
+			return cs.PanelChart(c, params, g, types, output)
+		}
+	case GraphType(typ) == Speed:
+		chartGen = withoutExtensions(cs.SpeedChart)
+	case GraphType(typ) == Pace:
+		chartGen = withoutExtensions(cs.PaceChart)
+	case GraphType(typ) == Steepness:
+		chartGen = withoutExtensions(cs.SteepnessChart)
+	case GraphType(typ) == VAM:
+		chartGen = withoutExtensions(cs.VerticalSpeedChart)
+	case GraphType(typ) == Ascent:
+		chartGen = withoutExtensions(cs.AscentDescentChart)
+	case GraphType(typ) == Grades:
+		chartGen = withoutExtensions(cs.GradeHistogramChart)
+	case GraphType(typ) == Splits:
+		chartGen = withoutExtensions(cs.SplitsChart)
+	case GraphType(typ) == Map:
+		chartGen = withoutExtensions(cs.MapChart)
+	case GraphType(typ) == TimeGap:
+		// Needs more files, see below
+	case GraphType(typ) == Zones:
+		if zonesFile == "" {
+			showHelpAndExit(1)
+		}
+		var zones gpxcharts.Zones
+		byts, err := ioutil.ReadFile(zonesFile)
+		panicIfErr(err)
+		panicIfErr(json.Unmarshal(byts, &zones))
+		chartGen = func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error) {
+			return cs.ZoneChart(c, params, g, zones, output)
+		}
+	case strings.HasPrefix(typ, string(Extension)) && len(typ) > len(Extension):
+		field := typ[len(Extension):]
+		chartGen = func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error) {
+			return cs.ExtensionChart(c, params, g, field, output)
+		}
 	default:
 		showHelpAndExit(1)
 	}
 
-	if len(flag.Args()) != 2 {
-		showHelpAndExit(1)
+	load := func(gpxFile string) gpxcharts.ExtendedGPX {
+		g, err := gpxcharts.ParseFile(gpxFile)
+		if err != nil {
+			panic("Error loading: " + gpxFile)
+		}
+		if g.ExtensionsErr != nil {
+			fmt.Fprintf(os.Stderr, "Ignoring extensions in %s: %v\n", gpxFile, g.ExtensionsErr)
+		}
+		*g, err = g.SelectSource(gpxcharts.Source(source), sourceNo)
+		panicIfErr(err)
+		if srtm {
+			panicIfErr(overwriteElevations(&g.GPX))
+		}
+		if smoothElevations {
+			for i := 0; i < 4; i++ {
+				g.SmoothVertical()
+			}
+		}
+		if gpxcharts.GPXName(g.GPX) == "" {
+			g.Name = strings.TrimSuffix(filepath.Base(gpxFile), filepath.Ext(gpxFile))
+		}
+		return *g
 	}
 
-	gpxFile := flag.Args()[0]
-	g, err := gpx.ParseFile(gpxFile)
-	if err != nil {
-		panic("Error loading: " + gpxFile)
+	switch command {
+	case ClimbsCommand:
+		if len(flag.Args()) != 1 {
+			showHelpAndExit(1)
+		}
+		climbs, err := cs.Climbs(params, load(flag.Arg(0)).GPX)
+		panicIfErr(err)
+		printClimbs(climbs, params.UnitTypeOrMetric(), jsonOutput)
+		return
+	case SplitsCommand:
+		if len(flag.Args()) != 1 {
+			showHelpAndExit(1)
+		}
+		splits, err := cs.Splits(params, load(flag.Arg(0)).GPX)
+		panicIfErr(err)
+		printSplits(splits, params, jsonOutput, csvOutput)
+		return
+	case ThumbnailsCommand:
+		if len(flag.Args()) != 2 || chartGen == nil {
+			showHelpAndExit(1)
+		}
+		saveThumbnails(c, params, flag.Arg(0), flag.Arg(1), "."+strings.TrimPrefix(thumbnailsExt, "."), load, chartGen)
+		return
 	}
-	outFile := flag.Args()[1]
 
-	if srtm {
-		panicIfErr(overwriteElevations(g))
+	if len(flag.Args()) < 2 {
+		showHelpAndExit(1)
 	}
-	if smoothElevations {
-		for i := 0; i < 4; i++ {
-			g.SmoothVertical()
-		}
+	overlayType := strings.Contains(", "+joinChartTypes()+", ", ", "+typ+", ") || (strings.HasPrefix(typ, string(Extension)) && len(typ) > len(Extension))
+	if len(flag.Args()) > 2 && GraphType(typ) != TimeGap && !overlayType {
+		fmt.Printf("Invalid type %s for more GPX files, only %s and %s<name> can be overlaid\n", typ, joinChartTypes(), Extension)
+		os.Exit(1)
 	}
 
-	bytes, err := chartGen(c, params, *g, gpxcharts.OutputExtension(filepath.Ext(outFile)))
+	var gpxs []gpxcharts.ExtendedGPX
+	for _, gpxFile := range flag.Args()[:len(flag.Args())-1] {
+		gpxs = append(gpxs, load(gpxFile))
+	}
+	outFile := flag.Args()[len(flag.Args())-1]
+	output := gpxcharts.OutputExtension(filepath.Ext(outFile))
+
+	var bytes []byte
+	if GraphType(typ) == TimeGap {
+		if len(gpxs) < 2 {
+			showHelpAndExit(1)
+		}
+		var attempts []gpx.GPX
+		for _, g := range gpxs[1:] {
+			attempts = append(attempts, g.GPX)
+		}
+		bytes, err = cs.TimeGapChart(c, params, gpxs[0].GPX, attempts, output)
+	} else if len(gpxs) > 1 {
+		bytes, err = cs.OverlayExtendedChart(c, params, gpxcharts.ChartType(typ), gpxs, output)
+	} else {
+		bytes, err = chartGen(c, params, gpxs[0], output)
+	}
 	panicIfErr(err)
 	err = ioutil.WriteFile(outFile, bytes, 0700)
 	panicIfErr(err)
@@ -149,6 +443,104 @@ func main() {
 	fmt.Printf("Saved chart to %s\n", outFile)
 }
 
+func printClimbs(climbs []gpxcharts.Climb, unitType gpxcharts.UnitType, jsonOutput bool) {
+	if jsonOutput {
+		if climbs == nil {
+			climbs = []gpxcharts.Climb{}
+		}
+		byts, err := json.MarshalIndent(climbs, "", "    ")
+		panicIfErr(err)
+		fmt.Println(string(byts))
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "#\tCategory\tStart\tEnd\tLength\tGain\tAverage\tMax\t")
+	for n, climb := range climbs {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s%%\t%s%%\t\n",
+			n+1,
+			climb.Category,
+			gpxcharts.FormatLength(climb.StartDistance, unitType),
+			gpxcharts.FormatLength(climb.EndDistance, unitType),
+			gpxcharts.FormatLength(climb.Length, unitType),
+			gpxcharts.FormatAltitude(climb.Gain, unitType),
+			gpxcharts.FormatFloat(climb.AverageGrade, 1),
+			gpxcharts.FormatFloat(climb.MaxGrade, 1))
+	}
+	panicIfErr(w.Flush())
+}
+
+func saveThumbnails(c context.Context, params gpxcharts.ChartParams, inDir, outDir, ext string, load func(string) gpxcharts.ExtendedGPX, chartGen func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error)) {
+	files, err := filepath.Glob(filepath.Join(inDir, "*.gpx"))
+	panicIfErr(err)
+	panicIfErr(os.MkdirAll(outDir, 0700))
+	for _, gpxFile := range files {
+		bytes, err := chartGen(c, params, load(gpxFile), gpxcharts.OutputExtension(ext))
+		panicIfErr(err)
+		outFile := filepath.Join(outDir, strings.TrimSuffix(filepath.Base(gpxFile), filepath.Ext(gpxFile))+ext)
+		panicIfErr(ioutil.WriteFile(outFile, bytes, 0700))
+		fmt.Printf("Saved thumbnail %s\n", outFile)
+	}
+}
+
+func isFlagSet(name string) bool {
+	var found bool
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func printSplits(splits []gpxcharts.Split, params gpxcharts.ChartParams, jsonOutput, csvOutput bool) {
+	switch {
+	case jsonOutput:
+		if splits == nil {
+			splits = []gpxcharts.Split{}
+		}
+		byts, err := json.MarshalIndent(splits, "", "    ")
+		panicIfErr(err)
+		fmt.Println(string(byts))
+	case csvOutput:
+		w := csv.NewWriter(os.Stdout)
+		panicIfErr(w.Write([]string{"no", "start_distance", "distance", "duration", "speed", "ascent", "descent"}))
+		for n, split := range splits {
+			panicIfErr(w.Write([]string{
+				strconv.Itoa(n + 1),
+				gpxcharts.FormatFloat(split.StartDistance, 1),
+				gpxcharts.FormatFloat(split.Distance, 1),
+				gpxcharts.FormatFloat(split.Duration, 1),
+				gpxcharts.FormatFloat(split.Speed, 3),
+				gpxcharts.FormatFloat(split.Ascent, 1),
+				gpxcharts.FormatFloat(split.Descent, 1),
+			}))
+		}
+		w.Flush()
+		panicIfErr(w.Error())
+	default:
+		unitType, paceUnit := params.UnitTypeOrMetric(), params.PaceUnitOrDefault()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "#\tEnd\tTime\tPace\tSpeed\tAscent\tDescent\t")
+		for n, split := range splits {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+				n+1,
+				gpxcharts.FormatLength(split.StartDistance+split.Distance, unitType),
+				gpxcharts.FormatDuration(split.Duration),
+				gpxcharts.FormatPace(split.Pace(), paceUnit),
+				gpxcharts.FormatSpeed(split.Speed, unitType, false),
+				gpxcharts.FormatAltitude(split.Ascent, unitType),
+				gpxcharts.FormatAltitude(split.Descent, unitType))
+		}
+		panicIfErr(w.Flush())
+	}
+}
+
+func withoutExtensions(chartGen func(c context.Context, params gpxcharts.ChartParams, g gpx.GPX, output gpxcharts.OutputExtension) ([]byte, error)) func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error) {
+	return func(c context.Context, params gpxcharts.ChartParams, g gpxcharts.ExtendedGPX, output gpxcharts.OutputExtension) ([]byte, error) {
+		return chartGen(c, params, g.GPX, output)
+	}
+}
+
 func overwriteElevations(g *gpx.GPX) error {
 	srtm, err := geoelevations.NewSrtm(http.DefaultClient)
 	if err != nil {
@@ -175,22 +567,108 @@ func showHelpAndExit(code int) {
 	fmt.Println()
 	fmt.Println("gpxchart [options] in_file.gpx out_file.png")
 	fmt.Println("gpxchart [options] in_file.gpx out_file.svg")
+	fmt.Println("gpxchart [options] in_file1.gpx in_file2.gpx ... out_file.png")
+	fmt.Println("gpxchart [options] -t gap reference.gpx attempt1.gpx ... out_file.png")
+	fmt.Println("gpxchart [options] climbs in_file.gpx")
+	fmt.Println("gpxchart [options] splits in_file.gpx")
+	fmt.Println("gpxchart [options] thumbnails in_dir out_dir")
 	fmt.Println()
 	flag.Usage()
 	os.Exit(code)
 }
 
-func prepareFont() string {
-	home, err := os.UserHomeDir()
-	panicIfErr(err)
-	dir := path.Join(home, ".cache", "gpxcharts", "font")
-	panicIfErr(os.MkdirAll(dir, 0700))
-	file := path.Join(dir, "luxisr.ttf")
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		fmt.Println("Saving font file to", file)
-		panicIfErr(ioutil.WriteFile(file, LuxiFont, 0700))
-	}
-	return dir
+func joinXAxisModes() string {
+	var modes []string
+	for _, mode := range gpxcharts.AllXAxisModes() {
+		modes = append(modes, string(mode))
+	}
+	return strings.Join(modes, ", ")
+}
+
+func joinPaceUnits() string {
+	var units []string
+	for _, unit := range gpxcharts.AllPaceUnits() {
+		units = append(units, string(unit))
+	}
+	return strings.Join(units, ", ")
+}
+
+func joinChartTypes() string {
+	var types []string
+	for _, typ := range gpxcharts.AllChartTypes() {
+		types = append(types, string(typ))
+	}
+	return strings.Join(types, ", ")
+}
+
+func joinCorners() string {
+	var corners []string
+	for _, corner := range gpxcharts.AllCorners() {
+		corners = append(corners, string(corner))
+	}
+	return strings.Join(corners, ", ")
+}
+
+func joinStatsFields() string {
+	var fields []string
+	for _, field := range gpxcharts.AllStatsFields() {
+		fields = append(fields, string(field))
+	}
+	return strings.Join(fields, ", ")
+}
+
+func joinSources() string {
+	var sources []string
+	for _, source := range gpxcharts.AllSources() {
+		sources = append(sources, string(source))
+	}
+	return strings.Join(sources, ", ")
+}
+
+func joinGapPolicies() string {
+	var policies []string
+	for _, policy := range gpxcharts.AllGapPolicies() {
+		policies = append(policies, string(policy))
+	}
+	return strings.Join(policies, ", ")
+}
+
+func joinFontStyles() string {
+	var styles []string
+	for _, style := range gpxcharts.AllFontStyles() {
+		styles = append(styles, string(style))
+	}
+	return strings.Join(styles, ", ")
+}
+
+func joinThemeNames() string {
+	var names []string
+	for _, name := range gpxcharts.AllThemeNames() {
+		names = append(names, string(name))
+	}
+	return strings.Join(names, ", ")
+}
+
+func joinSplitMetrics() string {
+	var metrics []string
+	for _, metric := range gpxcharts.AllSplitMetrics() {
+		metrics = append(metrics, string(metric))
+	}
+	return strings.Join(metrics, ", ")
+}
+
+func parseGradeColors(str string) gpxcharts.GradeColors {
+	var res gpxcharts.GradeColors
+	for n, part := range strings.Split(str, ",") {
+		if n%2 == 0 {
+			c, err := gpxcharts.ParseHexColor(part)
+			panicIfErr(err)
+			res.Colors = append(res.Colors, c)
+		} else {
+			res.Edges = append(res.Edges, parseFloats(part)...)
+		}
+	}
+	return res
 }
 
 func twoInts(str string) (int, int) {
@@ -4,6 +4,7 @@ go 1.13
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0
 	github.com/llgcode/draw2d v0.0.0-20200603164053-19660b984a28
 	github.com/stretchr/testify v1.6.1
 	github.com/tkrajina/go-elevations v0.0.0-20200416152435-2c9e0bec991f
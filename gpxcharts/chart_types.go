@@ -0,0 +1,84 @@
+package gpxcharts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChartType is a line chart type which can be combined with others (for
+// example in DualAxisChart)
+type ChartType string
+
+const (
+	ChartTypeElevation     ChartType = "elevation"
+	ChartTypeSpeed         ChartType = "speed"
+	ChartTypePace          ChartType = "pace"
+	ChartTypeVerticalSpeed ChartType = "vam"
+	ChartTypeSteepness     ChartType = "steepness"
+
+	extensionChartTypePrefix = "ext:"
+)
+
+func AllChartTypes() []ChartType {
+	return []ChartType{
+		ChartTypeElevation,
+		ChartTypeSpeed,
+		ChartTypePace,
+		ChartTypeVerticalSpeed,
+		ChartTypeSteepness,
+	}
+}
+
+// ExtensionChartType is the chart type for a numeric track point extension (for example "gpxtpx:hr")
+func ExtensionChartType(field string) ChartType {
+	return ChartType(extensionChartTypePrefix + field)
+}
+
+func (ct ChartType) extensionField() (string, bool) {
+	if strings.HasPrefix(string(ct), extensionChartTypePrefix) && len(ct) > len(extensionChartTypePrefix) {
+		return string(ct)[len(extensionChartTypePrefix):], true
+	}
+	return "", false
+}
+
+// Name is used in legends
+func (ct ChartType) Name() string {
+	if field, is := ct.extensionField(); is {
+		return localName(field)
+	}
+	switch ct {
+	case ChartTypeElevation:
+		return "Elevation"
+	case ChartTypeSpeed:
+		return "Speed"
+	case ChartTypePace:
+		return "Pace"
+	case ChartTypeVerticalSpeed:
+		return "VAM"
+	case ChartTypeSteepness:
+		return "Steepness"
+	default:
+		return string(ct)
+	}
+}
+
+// chartTypeParams prepares the points and axes for the chart type
+func (cs ChartService) chartTypeParams(typ ChartType, params ChartParams, g ExtendedGPX) (ChartParams, error) {
+	if field, is := typ.extensionField(); is {
+		return cs.extensionChartParams(params, g, field)
+	}
+	switch typ {
+	case ChartTypeElevation:
+		return cs.elevationChartParams(params, g.GPX)
+	case ChartTypeSpeed:
+		return cs.speedChartParams(params, g.GPX)
+	case ChartTypePace:
+		return cs.paceChartParams(params, g.GPX)
+	case ChartTypeVerticalSpeed:
+		return cs.verticalSpeedChartParams(params, g.GPX)
+	case ChartTypeSteepness:
+		return cs.steepnessChartParams(params, g.GPX)
+	default:
+		return params, fmt.Errorf("invalid chart type: %s", typ)
+	}
+}
@@ -0,0 +1,226 @@
+package gpxcharts
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// Elevations are resampled every climbSampleDistance meters, and smoothed with a moving average
+	// of climbSmoothingSamples samples on both sides
+	climbSampleDistance   = 50.
+	climbSmoothingSamples = 2
+	// A climb ends when the elevation drops more than climbMaxDescent meters below its top, or
+	// when there is no new top for climbMaxFlat meters
+	climbMaxDescent = 20.
+	climbMaxFlat    = 1000.
+	// Flat parts (with grades below this, in percent) at the start of climbs are ignored
+	climbFlatGrade = 1.
+	// Minimum average grade (in percent) and score for a climb
+	climbMinGrade = 3.
+	climbMinScore = 8000.
+)
+
+type ClimbCategory string
+
+const (
+	ClimbCategory4  ClimbCategory = "Cat 4"
+	ClimbCategory3  ClimbCategory = "Cat 3"
+	ClimbCategory2  ClimbCategory = "Cat 2"
+	ClimbCategory1  ClimbCategory = "Cat 1"
+	ClimbCategoryHC ClimbCategory = "HC"
+)
+
+// climbCategories are ordered by the minimum score (length in meters × average grade in percent)
+var climbCategories = []struct {
+	minScore float64
+	category ClimbCategory
+	color    color.RGBA
+}{
+	{80000, ClimbCategoryHC, color.RGBA{0x23, 0x00, 0x0f, 0x40}},
+	{64000, ClimbCategory1, color.RGBA{0x35, 0x08, 0x08, 0x40}},
+	{32000, ClimbCategory2, color.RGBA{0x3a, 0x14, 0x00, 0x40}},
+	{16000, ClimbCategory3, color.RGBA{0x3c, 0x23, 0x00, 0x40}},
+	{climbMinScore, ClimbCategory4, color.RGBA{0x3c, 0x32, 0x00, 0x40}},
+}
+
+// Climb is a climb found by ChartService.Climbs, distances are in meters from the start (without
+// gaps between segments), grades are in percent
+type Climb struct {
+	StartDistance float64       `json:"start_distance"`
+	EndDistance   float64       `json:"end_distance"`
+	Length        float64       `json:"length"`
+	Gain          float64       `json:"gain"`
+	AverageGrade  float64       `json:"average_grade"`
+	MaxGrade      float64       `json:"max_grade"`
+	Category      ClimbCategory `json:"category"`
+	// StartX and EndX are the chart X values (by ChartParams.XAxisMode)
+	StartX float64 `json:"-"`
+	EndX   float64 `json:"-"`
+}
+
+// Score is the climb length (in meters) × average grade (in percent)
+func (c Climb) Score() float64 {
+	return c.Length * c.AverageGrade
+}
+
+func (c Climb) color() color.RGBA {
+	for _, cat := range climbCategories {
+		if cat.category == c.Category {
+			return cat.color
+		}
+	}
+	return defaultFillColor
+}
+
+// Label is (for example) "7.2km @ 6.1%"
+func (c Climb) Label(unitType UnitType) string {
+	return FormatLength(c.Length, unitType) + " @ " + FormatFloat(c.AverageGrade, 1) + "%"
+}
+
+type climbSample struct {
+	distance, x, elevation float64
+}
+
+// climbSamples returns elevations (and X values) resampled every climbSampleDistance meters, and
+// smoothed. Points without elevation (or without X) are ignored.
+func climbSamples(params ChartParams, g gpx.GPX) []climbSample {
+	var points []climbSample
+	xc := newXAxisCounter(params, g)
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for n, pt := range segment.Points {
+				x, ok := xc.next(pt, n == 0)
+				if ok && pt.Elevation.NotNull() {
+					points = append(points, climbSample{distance: xc.distance, x: x, elevation: pt.Elevation.Value()})
+				}
+			}
+		}
+	}
+	if len(points) < 2 {
+		return nil
+	}
+
+	var resampled []climbSample
+	n := 0
+	last := points[len(points)-1].distance
+	for d := points[0].distance; ; d = math.Min(d+climbSampleDistance, last) {
+		for n < len(points)-2 && points[n+1].distance < d {
+			n++
+		}
+		p1, p2 := points[n], points[n+1]
+		f := 0.
+		if p2.distance > p1.distance {
+			f = math.Max(0, math.Min(1, (d-p1.distance)/(p2.distance-p1.distance)))
+		}
+		resampled = append(resampled, climbSample{
+			distance:  d,
+			x:         p1.x + f*(p2.x-p1.x),
+			elevation: p1.elevation + f*(p2.elevation-p1.elevation),
+		})
+		if d >= last {
+			break
+		}
+	}
+
+	smoothed := make([]climbSample, len(resampled))
+	for n := range resampled {
+		var sum float64
+		from, to := n-climbSmoothingSamples, n+climbSmoothingSamples
+		if from < 0 {
+			from = 0
+		}
+		if to > len(resampled)-1 {
+			to = len(resampled) - 1
+		}
+		for i := from; i <= to; i++ {
+			sum += resampled[i].elevation
+		}
+		smoothed[n] = resampled[n]
+		smoothed[n].elevation = sum / float64(to-from+1)
+	}
+	return smoothed
+}
+
+func grade(s1, s2 climbSample) float64 {
+	if s2.distance <= s1.distance {
+		return 0
+	}
+	return 100 * (s2.elevation - s1.elevation) / (s2.distance - s1.distance)
+}
+
+// newClimb returns the climb from the first to the last sample, if it is steep and long enough
+func newClimb(samples []climbSample) (Climb, bool) {
+	for len(samples) > 2 && grade(samples[0], samples[1]) < climbFlatGrade {
+		samples = samples[1:]
+	}
+	first, last := samples[0], samples[len(samples)-1]
+	climb := Climb{
+		StartDistance: first.distance,
+		EndDistance:   last.distance,
+		Length:        last.distance - first.distance,
+		Gain:          last.elevation - first.elevation,
+		StartX:        first.x,
+		EndX:          last.x,
+	}
+	if climb.Length <= 0 {
+		return climb, false
+	}
+	climb.AverageGrade = 100 * climb.Gain / climb.Length
+	for n := 1; n < len(samples); n++ {
+		climb.MaxGrade = math.Max(climb.MaxGrade, grade(samples[n-1], samples[n]))
+	}
+	if climb.AverageGrade < climbMinGrade {
+		return climb, false
+	}
+	for _, cat := range climbCategories {
+		if climb.Score() >= cat.minScore {
+			climb.Category = cat.category
+			return climb, true
+		}
+	}
+	return climb, false
+}
+
+// Climbs finds (categorized) climbs on the smoothed elevations
+func (cs ChartService) Climbs(params ChartParams, g gpx.GPX) ([]Climb, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return nil, err
+	}
+	samples := climbSamples(params, g)
+
+	var climbs []Climb
+	addClimb := func(start, top int) {
+		if top > start {
+			if climb, ok := newClimb(samples[start : top+1]); ok {
+				climbs = append(climbs, climb)
+			}
+		}
+	}
+	start, top := 0, 0
+	for n := 1; n < len(samples); n++ {
+		if samples[n].elevation > samples[top].elevation {
+			top = n
+			continue
+		}
+		descent := samples[top].elevation - samples[n].elevation
+		flat := samples[n].distance - samples[top].distance
+		if descent > climbMaxDescent || flat > climbMaxFlat || samples[n].elevation < samples[start].elevation {
+			addClimb(start, top)
+			start, top = n, n
+		}
+	}
+	addClimb(start, top)
+	return climbs, nil
+}
+
+func climbSpans(climbs []Climb, unitType UnitType) []Span {
+	spans := make([]Span, len(climbs))
+	for n, climb := range climbs {
+		spans[n] = Span{MinX: climb.StartX, MaxX: climb.EndX, Color: climb.color(), Label: climb.Label(unitType)}
+	}
+	return spans
+}
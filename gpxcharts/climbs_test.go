@@ -0,0 +1,82 @@
+package gpxcharts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// profileGPX returns a track (on the equator, with points every 10m) with the given grades (in
+// percent) for every km
+func profileGPX(grades ...float64) gpx.GPX {
+	var segment gpx.GPXTrackSegment
+	ele := 100.
+	for n := 0; n <= len(grades)*100; n++ {
+		if n > 0 {
+			ele += grades[(n-1)/100] / 10
+		}
+		segment.Points = append(segment.Points, gpx.GPXPoint{Point: gpx.Point{Latitude: 0, Longitude: float64(n) * 10 / oneDegree, Elevation: *gpx.NewNullableFloat64(ele)}})
+	}
+	return gpx.GPX{Tracks: []gpx.GPXTrack{{Segments: []gpx.GPXTrackSegment{segment}}}}
+}
+
+func TestClimbs(t *testing.T) {
+	t.Parallel()
+
+	// Flat, 5km @ 6%, descent, a short bump (not a climb), 1km @ 10%:
+	g := profileGPX(0, 0, 6, 6, 6, 6, 6, -5, -5, -5, 0, 4, 0, 0, 10, 0)
+	climbs, err := chartService.Climbs(ChartParams{}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(climbs)) {
+		assert.InDelta(t, 2000, climbs[0].StartDistance, 150)
+		assert.InDelta(t, 7000, climbs[0].EndDistance, 150)
+		assert.InDelta(t, 5000, climbs[0].Length, 300)
+		assert.InDelta(t, 300, climbs[0].Gain, 15)
+		assert.InDelta(t, 6, climbs[0].AverageGrade, 0.5)
+		assert.InDelta(t, 6, climbs[0].MaxGrade, 0.5)
+		assert.Equal(t, ClimbCategory3, climbs[0].Category)
+		assert.Equal(t, climbs[0].StartDistance, climbs[0].StartX)
+
+		assert.InDelta(t, 14000, climbs[1].StartDistance, 150)
+		assert.InDelta(t, 10, climbs[1].MaxGrade, 0.5)
+		assert.Equal(t, ClimbCategory4, climbs[1].Category)
+	}
+
+	climbs, err = chartService.Climbs(ChartParams{}, profileGPX(12, 12, 12, 12, 12, 12, 12, 12, 12))
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(climbs)) {
+		assert.Equal(t, ClimbCategoryHC, climbs[0].Category)
+	}
+	assert.Equal(t, "7.2km @ 6.1%", Climb{Length: 7200, AverageGrade: 6.1}.Label(UnitTypeMetric))
+
+	climbs, err = chartService.Climbs(ChartParams{}, profileGPX(0, -3, 2, 2, 2, -1))
+	assert.Nil(t, err)
+	assert.Empty(t, climbs)
+
+	climbs, err = chartService.Climbs(ChartParams{}, gpx.GPX{})
+	assert.Nil(t, err)
+	assert.Empty(t, climbs)
+}
+
+func TestElevationChartWithClimbs(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	params := ChartParams{Width: 900, Height: 200, Climbs: true, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}}
+	prepared, err := chartService.elevationChartParams(params, *g)
+	assert.Nil(t, err)
+	climbs, err := chartService.Climbs(params, *g)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, climbs)
+	assert.Equal(t, climbSpans(climbs, UnitTypeMetric), prepared.Spans)
+
+	var rows labelRows
+	assert.Equal(t, 0, rows.place(0, 10))
+	assert.Equal(t, 1, rows.place(5, 10))
+	assert.Equal(t, 0, rows.place(20, 10))
+	assert.Equal(t, 2, rows.place(0, 30))
+}
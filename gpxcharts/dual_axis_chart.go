@@ -0,0 +1,48 @@
+package gpxcharts
+
+import (
+	"context"
+	"image/color"
+)
+
+var y2LineColor = color.RGBA{0xd0, 0x30, 0x20, 0xff}
+
+// DualAxisChart draws the elevation (filled, with the axis on the left) and
+// another chart type as a line with its own axis on the right. The second
+// axis is configured with Y2Axis and MinY2/MaxY2.
+func (cs ChartService) DualAxisChart(c context.Context, params ChartParams, g ExtendedGPX, y2Type ChartType, output OutputExtension) ([]byte, error) {
+	params, err := cs.dualAxisChartParams(params, g, y2Type)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+func (cs ChartService) dualAxisChartParams(params ChartParams, g ExtendedGPX, y2Type ChartType) (ChartParams, error) {
+	y2Params := params
+	y2Params.YAxis = params.Y2Axis
+	y2Params.YAxis.Show = params.YAxis.Show
+	if y2Params.YAxis.FontSize == 0 {
+		y2Params.YAxis.FontSize = params.YAxis.FontSize
+	}
+	y2Params.MinY, y2Params.MaxY = params.MinY2, params.MaxY2
+	y2Params.Series = nil
+	y2Params, err := cs.chartTypeParams(y2Type, y2Params, g)
+	if err != nil {
+		return params, err
+	}
+
+	params, err = cs.elevationChartParams(params, g.GPX)
+	if err != nil {
+		return params, err
+	}
+	params.Series[0].Label = ChartTypeElevation.Name()
+	params.Series = append(params.Series, Series{Points: y2Params.mainPoints(), Color: y2LineColor, Label: y2Type.Name(), Y2: true})
+	params.Y2Axis = y2Params.YAxis
+	params.MinY2, params.MaxY2 = y2Params.MinY, y2Params.MaxY
+	if params.ChartMargin.Right == 0 {
+		// Space for the right axis labels:
+		params.ChartMargin.Right = params.ChartMargin.Left
+	}
+	return params, nil
+}
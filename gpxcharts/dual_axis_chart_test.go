@@ -0,0 +1,57 @@
+package gpxcharts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDualAxisChart(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	for _, typ := range append(AllChartTypes(), ExtensionChartType("gpxtpx:hr")) {
+		params := ChartParams{XAxis: Axis{Show: true}, YAxis: Axis{Show: true, FontSize: 7}, ChartMargin: Padding{Left: 40}}
+		prepared, err := chartService.dualAxisChartParams(params, *g, typ)
+		assert.Nil(t, err)
+		y2Params, err := chartService.chartTypeParams(typ, ChartParams{}, *g)
+		assert.Nil(t, err)
+		if assert.Equal(t, 2, len(prepared.Series), typ) {
+			assert.Equal(t, ChartTypeElevation.Name(), prepared.Series[0].Label)
+			assert.False(t, prepared.Series[0].Y2)
+			assert.Equal(t, typ.Name(), prepared.Series[1].Label)
+			assert.True(t, prepared.Series[1].Y2)
+			assert.Equal(t, y2Params.mainPoints(), prepared.Series[1].Points, typ)
+		}
+		assert.True(t, prepared.Y2Axis.Show)
+		assert.Equal(t, 7., prepared.Y2Axis.FontSize)
+		assert.Equal(t, 40., prepared.ChartMargin.Right)
+	}
+
+	_, err = chartService.DualAxisChart(context.Background(), ChartParams{Width: 900, Height: 200}, *g, ChartType("invalid"), OutputPNG)
+	assert.NotNil(t, err)
+}
+
+func TestChartTypesShareXAxis(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/parenzana.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	elevation, err := chartService.chartTypeParams(ChartTypeElevation, ChartParams{}, *g)
+	assert.Nil(t, err)
+	maxX := elevation.mainPoints()[len(elevation.mainPoints())-1].X
+	for _, typ := range []ChartType{ChartTypeSpeed, ChartTypePace, ChartTypeSteepness} {
+		params, err := chartService.chartTypeParams(typ, ChartParams{}, *g)
+		assert.Nil(t, err)
+		// Reduced tracks are shorter, but X values must be computed on the original track:
+		assert.InEpsilon(t, maxX, params.mainPoints()[len(params.mainPoints())-1].X, 0.01, typ)
+	}
+}
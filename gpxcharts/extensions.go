@@ -0,0 +1,192 @@
+package gpxcharts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// PointExtensions contains numeric extension values of a single point, keyed by
+// the element name with its namespace prefix (for example "gpxtpx:hr").
+type PointExtensions map[string]float64
+
+// Get returns the value of the field. If there is no exact match, the field is
+// matched by local name only, so that "gpxtpx:hr" finds "ns3:hr" (Garmin
+// Connect uses different prefixes for the same namespace).
+func (pe PointExtensions) Get(field string) (float64, bool) {
+	if v, found := pe[field]; found {
+		return v, true
+	}
+	local := localName(field)
+	for k, v := range pe {
+		if localName(k) == local {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+func localName(field string) string {
+	if pos := strings.LastIndex(field, ":"); pos >= 0 {
+		return field[pos+1:]
+	}
+	return field
+}
+
+// ExtendedGPX is a GPX with the track point extensions (ignored by gpxgo).
+type ExtendedGPX struct {
+	gpx.GPX
+	// TrackExtensions[trackNo][segmentNo][pointNo]
+	TrackExtensions [][][]PointExtensions
+	// ExtensionsErr is set if the extensions were ignored (TrackExtensions is empty then)
+	ExtensionsErr error
+}
+
+// Extensions returns the extension values of a track point (never nil).
+func (eg ExtendedGPX) Extensions(trackNo, segmentNo, pointNo int) PointExtensions {
+	if trackNo < len(eg.TrackExtensions) && segmentNo < len(eg.TrackExtensions[trackNo]) && pointNo < len(eg.TrackExtensions[trackNo][segmentNo]) {
+		if pe := eg.TrackExtensions[trackNo][segmentNo][pointNo]; pe != nil {
+			return pe
+		}
+	}
+	return PointExtensions{}
+}
+
+// ExtensionFields returns all extension field names found in the track points.
+func (eg ExtendedGPX) ExtensionFields() []string {
+	var res []string
+	found := map[string]bool{}
+	for _, track := range eg.TrackExtensions {
+		for _, segment := range track {
+			for _, pe := range segment {
+				for k := range pe {
+					if !found[k] {
+						found[k] = true
+						res = append(res, k)
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+func ParseFile(fileName string) (*ExtendedGPX, error) {
+	byts, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return ParseBytes(byts)
+}
+
+// ParseBytes parses the GPX and its track point extensions. If the extensions
+// can't be matched with the gpxgo track points, the GPX is returned without
+// them, and ExtensionsErr explains why.
+func ParseBytes(byts []byte) (*ExtendedGPX, error) {
+	g, err := gpx.ParseBytes(byts)
+	if err != nil {
+		return nil, err
+	}
+	ext, err := parseTrackExtensions(byts)
+	if err == nil {
+		err = checkTrackExtensions(*g, ext)
+	}
+	if err != nil {
+		return &ExtendedGPX{GPX: *g, ExtensionsErr: fmt.Errorf("error parsing extensions: %w", err)}, nil
+	}
+	return &ExtendedGPX{GPX: *g, TrackExtensions: ext}, nil
+}
+
+func checkTrackExtensions(g gpx.GPX, ext [][][]PointExtensions) error {
+	if len(ext) != len(g.Tracks) {
+		return fmt.Errorf("invalid number of tracks with extensions: %d!=%d", len(ext), len(g.Tracks))
+	}
+	for trackNo := range g.Tracks {
+		if len(ext[trackNo]) != len(g.Tracks[trackNo].Segments) {
+			return fmt.Errorf("invalid number of segments with extensions in track #%d", trackNo)
+		}
+		for segmentNo := range g.Tracks[trackNo].Segments {
+			if len(ext[trackNo][segmentNo]) != len(g.Tracks[trackNo].Segments[segmentNo].Points) {
+				return fmt.Errorf("invalid number of points with extensions in track #%d, segment #%d", trackNo, segmentNo)
+			}
+		}
+	}
+	return nil
+}
+
+// parseTrackExtensions collects all numeric leaf elements found in <extensions>
+// (GPX 1.1) or in a foreign namespace (GPX 1.0) of every <trkpt>.
+func parseTrackExtensions(byts []byte) ([][][]PointExtensions, error) {
+	var (
+		res       [][][]PointExtensions
+		point     PointExtensions
+		path      []xml.Name
+		extDepth  = -1
+		charData  bytes.Buffer
+		hasChilds bool
+	)
+	decoder := xml.NewDecoder(bytes.NewReader(byts))
+	decoder.Strict = false
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			path = append(path, t.Name)
+			hasChilds = false
+			charData.Reset()
+			switch {
+			case t.Name.Local == "trk" && point == nil:
+				res = append(res, [][]PointExtensions{})
+			case t.Name.Local == "trkseg" && point == nil && len(res) > 0:
+				res[len(res)-1] = append(res[len(res)-1], []PointExtensions{})
+			case t.Name.Local == "trkpt" && point == nil && len(res) > 0 && len(res[len(res)-1]) > 0:
+				point = PointExtensions{}
+			case point != nil && extDepth < 0 && (t.Name.Local == "extensions" || t.Name.Space != ""):
+				extDepth = len(path)
+			}
+		case xml.CharData:
+			charData.Write(t)
+		case xml.EndElement:
+			if len(path) == 0 {
+				return nil, fmt.Errorf("unexpected end element %s", t.Name.Local)
+			}
+			name := path[len(path)-1]
+			if point != nil && extDepth >= 0 && !hasChilds && name.Local != "extensions" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(charData.String()), 64); err == nil {
+					point[fieldName(name)] = f
+				}
+			}
+			if len(path) == extDepth {
+				extDepth = -1
+			}
+			if name.Local == "trkpt" && point != nil && extDepth < 0 {
+				track := res[len(res)-1]
+				track[len(track)-1] = append(track[len(track)-1], point)
+				point = nil
+			}
+			path = path[:len(path)-1]
+			hasChilds = true
+			charData.Reset()
+		}
+	}
+	return res, nil
+}
+
+func fieldName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
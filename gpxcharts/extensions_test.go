@@ -0,0 +1,105 @@
+package gpxcharts
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExtensions(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, len(g.TrackExtensions))
+	assert.Equal(t, 2, len(g.TrackExtensions[0]))
+	assert.Equal(t, len(g.Tracks[0].Segments[0].Points), len(g.TrackExtensions[0][0]))
+	assert.Equal(t, len(g.Tracks[0].Segments[1].Points), len(g.TrackExtensions[0][1]))
+
+	pe := g.Extensions(0, 0, 0)
+	assert.Equal(t, PointExtensions{"ns3:atemp": 17, "ns3:hr": 98, "ns3:cad": 80}, pe)
+
+	hr, found := pe.Get("gpxtpx:hr")
+	assert.True(t, found)
+	assert.Equal(t, 98.0, hr)
+	_, found = pe.Get("power")
+	assert.False(t, found)
+
+	assert.ElementsMatch(t, []string{"ns3:atemp", "ns3:hr", "ns3:cad"}, g.ExtensionFields())
+	assert.Empty(t, g.Extensions(5, 5, 5))
+}
+
+func TestParseWithoutExtensions(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/track.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, len(g.Tracks[0].Segments[0].Points), len(g.TrackExtensions[0][0]))
+	assert.Empty(t, g.ExtensionFields())
+}
+
+func TestExtensionChart(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	for _, field := range []string{"gpxtpx:hr", "cad", "ns3:atemp"} {
+		params, err := chartService.extensionChartParams(ChartParams{}, *g, field)
+		assert.Nil(t, err)
+		var (
+			values   int
+			min, max = math.MaxFloat64, -math.MaxFloat64
+		)
+		for trackNo, track := range g.Tracks {
+			for segmentNo, segment := range track.Segments {
+				for n := range segment.Points {
+					if v, found := g.Extensions(trackNo, segmentNo, n).Get(field); found {
+						values++
+						min, max = math.Min(min, v), math.Max(max, v)
+					}
+				}
+			}
+		}
+		assert.True(t, values > 0, field)
+		points := params.mainPoints()
+		assert.Equal(t, values, len(points), field)
+		minY, maxY := minMaxY(points)
+		assert.Equal(t, min, minY, field)
+		assert.Equal(t, max, maxY, field)
+	}
+
+	params, err := chartService.extensionChartParams(ChartParams{}, *g, "unknown")
+	assert.Nil(t, err)
+	assert.Empty(t, params.mainPoints())
+}
+
+func TestParseWithUnmatchedExtensions(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseBytes([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+	<metadata><extensions><trk><trkseg><trkpt lat="1" lon="1"></trkpt></trkseg></trk></extensions></metadata>
+	<trk><trkseg>
+		<trkpt lat="45.0" lon="13.0"><ele>10</ele><extensions><hr>100</hr></extensions></trkpt>
+		<trkpt lat="45.001" lon="13.0"><ele>12</ele><extensions><hr>110</hr></extensions></trkpt>
+	</trkseg></trk>
+</gpx>`))
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	assert.NotNil(t, g.ExtensionsErr)
+	assert.Empty(t, g.TrackExtensions)
+	assert.Equal(t, 2, len(g.Tracks[0].Segments[0].Points))
+	assert.Empty(t, g.Extensions(0, 0, 0))
+
+	g, err = ParseFile("../test_files/garmin.gpx")
+	assert.Nil(t, err)
+	assert.Nil(t, g.ExtensionsErr)
+}
@@ -0,0 +1,193 @@
+package gpxcharts
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/golang/freetype/truetype"
+	"github.com/llgcode/draw2d"
+	"github.com/llgcode/draw2d/draw2dbase"
+)
+
+// DefaultFontFamily is the family of fonts loaded without a family name
+const DefaultFontFamily = "default"
+
+// defaultFontSize is the size of axis labels and annotations
+const defaultFontSize = 8.0
+
+// FontStyle of a font loaded with ChartService.LoadFont
+type FontStyle string
+
+const (
+	FontRegular    FontStyle = "regular"
+	FontBold       FontStyle = "bold"
+	FontItalic     FontStyle = "italic"
+	FontBoldItalic FontStyle = "bold-italic"
+)
+
+func AllFontStyles() []FontStyle {
+	return []FontStyle{
+		FontRegular,
+		FontBold,
+		FontItalic,
+		FontBoldItalic,
+	}
+}
+
+func (fs FontStyle) draw2dStyle() draw2d.FontStyle {
+	switch fs {
+	case FontBold:
+		return draw2d.FontStyleBold
+	case FontItalic:
+		return draw2d.FontStyleItalic
+	case FontBoldItalic:
+		return draw2d.FontStyleBold | draw2d.FontStyleItalic
+	}
+	return draw2d.FontStyleNormal
+}
+
+// Font of a chart element, zero values are defaults (DefaultFontFamily, regular, the size depends on
+// the element)
+type Font struct {
+	Family string
+	Size   float64
+	Style  FontStyle
+}
+
+// orDefault returns the font with the given size if the size is not set
+func (f Font) orDefault(size float64) Font {
+	if f.Size <= 0 {
+		f.Size = size
+	}
+	return f
+}
+
+// labelFontSize is the size of X axis labels, the default size of titles and annotations
+func (cp ChartParams) labelFontSize() float64 {
+	if cp.XAxis.FontSize > 0 {
+		return cp.XAxis.FontSize
+	}
+	return cp.AxisFont.orDefault(defaultFontSize).Size
+}
+
+// annotationFont is the font of span, marker, bar and separator labels, legend and stats boxes
+func (cp ChartParams) annotationFont() Font {
+	return cp.AnnotationFont.orDefault(cp.labelFontSize())
+}
+
+func (f Font) data() draw2d.FontData {
+	family := f.Family
+	if family == "" {
+		family = DefaultFontFamily
+	}
+	return draw2d.FontData{Name: family, Family: draw2d.FontFamilySans, Style: f.Style.draw2dStyle()}
+}
+
+// fontCache implements draw2d.FontCache for ChartService fonts. Fonts not found (for example a family
+// without the bold style) fall back to the regular style of the family, and then to the default font.
+type fontCache struct {
+	sync.RWMutex
+	fonts map[string]*truetype.Font
+	// dir (if set) is searched for fonts (by draw2d file names, for example luxisr.ttf) not loaded
+	// with ChartService.LoadFont
+	dir string
+	// defaultFont is the first loaded font
+	defaultFont *truetype.Font
+}
+
+var _ draw2d.FontCache = (*fontCache)(nil)
+
+func newFontCache() *fontCache {
+	return &fontCache{fonts: map[string]*truetype.Font{}}
+}
+
+func (fc *fontCache) Store(fontData draw2d.FontData, font *truetype.Font) {
+	fc.Lock()
+	defer fc.Unlock()
+	fc.fonts[draw2d.FontFileName(fontData)] = font
+	if fc.defaultFont == nil {
+		fc.defaultFont = font
+	}
+}
+
+func (fc *fontCache) Load(fontData draw2d.FontData) (*truetype.Font, error) {
+	regular := fontData
+	regular.Style = draw2d.FontStyleNormal
+	for _, fd := range []draw2d.FontData{fontData, regular, draw2dbase.DefaultFontData} {
+		if font := fc.load(fd); font != nil {
+			return font, nil
+		}
+	}
+	fc.RLock()
+	defer fc.RUnlock()
+	if fc.defaultFont != nil {
+		return fc.defaultFont, nil
+	}
+	return nil, fmt.Errorf("font %s not found", fontData.Name)
+}
+
+func (fc *fontCache) load(fontData draw2d.FontData) *truetype.Font {
+	fileName := draw2d.FontFileName(fontData)
+	fc.RLock()
+	font, dir := fc.fonts[fileName], fc.dir
+	fc.RUnlock()
+	if font != nil || dir == "" {
+		return font
+	}
+	byts, err := ioutil.ReadFile(filepath.Join(dir, fileName))
+	if err != nil {
+		return nil
+	}
+	font, err = truetype.Parse(byts)
+	if err != nil {
+		return nil
+	}
+	fc.Store(fontData, font)
+	return font
+}
+
+// setFontDir sets the first existing directory from fontDirs as the directory with fonts
+func (fc *fontCache) setFontDir(fontDirs []string) error {
+	for _, fontDir := range fontDirs {
+		if _, err := os.Stat(fontDir); err == nil {
+			fc.Lock()
+			fc.dir = fontDir
+			fc.Unlock()
+			return nil
+		}
+	}
+	return errors.New("no font dir found")
+}
+
+// LoadFont loads a TTF font (for example from go:embed or a generated Go file) of the family (default
+// DefaultFontFamily) and style. The first loaded font is used for texts with fonts not found.
+func (cs *ChartService) LoadFont(family string, style FontStyle, byts []byte) error {
+	font, err := truetype.Parse(byts)
+	if err != nil {
+		return fmt.Errorf("invalid font: %w", err)
+	}
+	if cs.fonts == nil {
+		cs.fonts = newFontCache()
+	}
+	cs.fonts.Store(Font{Family: family, Style: style}.data(), font)
+	return nil
+}
+
+// LoadFontFile loads a TTF font file, see LoadFont
+func (cs *ChartService) LoadFontFile(family string, style FontStyle, fileName string) error {
+	byts, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	return cs.LoadFont(family, style, byts)
+}
+
+// setFont sets the font (with the size) for the next strings
+func setFont(gc draw2d.GraphicContext, font Font) {
+	gc.SetFontData(font.data())
+	gc.SetFontSize(font.Size)
+}
@@ -0,0 +1,69 @@
+package gpxcharts
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/llgcode/draw2d"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFontCache(t *testing.T) {
+	t.Parallel()
+
+	byts, err := ioutil.ReadFile("../fonts/luxisr.ttf")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	cs, err := NewChartServiceWithFont(byts)
+	assert.Nil(t, err)
+	regular, err := cs.fonts.Load(Font{}.data())
+	assert.Nil(t, err)
+	assert.NotNil(t, regular)
+	// Not loaded, falls back to the regular style and the default font:
+	font, err := cs.fonts.Load(Font{Style: FontBold}.data())
+	assert.Nil(t, err)
+	assert.Equal(t, regular, font)
+	font, err = cs.fonts.Load(Font{Family: "unknown", Style: FontItalic}.data())
+	assert.Nil(t, err)
+	assert.Equal(t, regular, font)
+
+	assert.Nil(t, cs.LoadFontFile("other", FontBold, "../fonts/luxisr.ttf"))
+	bold, err := cs.fonts.Load(Font{Family: "other", Style: FontBold}.data())
+	assert.Nil(t, err)
+	assert.False(t, bold == regular)
+
+	assert.NotNil(t, cs.LoadFont("", FontRegular, []byte("not a font")))
+	assert.NotNil(t, cs.LoadFontFile("", FontRegular, "../fonts/unknown.ttf"))
+	_, err = NewChartServiceWithFont(nil)
+	assert.NotNil(t, err)
+
+	// Without fonts:
+	_, err = newFontCache().Load(draw2d.FontData{Name: "luxi"})
+	assert.NotNil(t, err)
+	_, err = NewChartService([]string{"../unknown"})
+	assert.NotNil(t, err)
+
+	cs, err = NewChartService([]string{"../unknown", "../fonts"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"../unknown", "../fonts"}, cs.FontDirs)
+	assert.Equal(t, "../fonts", cs.fonts.dir)
+
+	// Not created with a constructor:
+	cs = &ChartService{}
+	assert.Nil(t, cs.LoadFont("", FontRegular, byts))
+	font, err = cs.fonts.Load(Font{}.data())
+	assert.Nil(t, err)
+	assert.NotNil(t, font)
+}
+
+func TestAnnotationFont(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, Font{Size: defaultFontSize}, ChartParams{}.annotationFont())
+	assert.Equal(t, Font{Size: 10}, ChartParams{AxisFont: Font{Size: 10, Style: FontItalic}}.annotationFont())
+	assert.Equal(t, Font{Size: 6}, ChartParams{AxisFont: Font{Size: 10}, XAxis: Axis{FontSize: 6}, YAxis: Axis{FontSize: 12}}.annotationFont())
+	assert.Equal(t, Font{Size: 12, Style: FontBold}, ChartParams{XAxis: Axis{FontSize: 6}, AnnotationFont: Font{Size: 12, Style: FontBold}}.annotationFont())
+	assert.Equal(t, Font{Size: 6, Style: FontBold}, ChartParams{XAxis: Axis{FontSize: 6}, AnnotationFont: Font{Style: FontBold}}.annotationFont())
+}
@@ -0,0 +1,79 @@
+package gpxcharts
+
+import (
+	"image/color"
+	"math"
+)
+
+var gapColor = color.RGBA{0x80, 0x80, 0x80, 0xff}
+
+// GapPolicy defines how gaps between track segments (and tracks) are drawn
+type GapPolicy string
+
+const (
+	// GapConnect draws all segments as one line (default)
+	GapConnect GapPolicy = "connect"
+	// GapBreak breaks the line between segments
+	GapBreak GapPolicy = "break"
+	// GapHatch breaks the line, and draws a hatched area between segments
+	GapHatch GapPolicy = "hatch"
+)
+
+func AllGapPolicies() []GapPolicy {
+	return []GapPolicy{
+		GapConnect,
+		GapBreak,
+		GapHatch,
+	}
+}
+
+// lineBreak is the point between two segments in Points (or Line.Points) if the line is broken
+var lineBreak = Point{math.NaN(), math.NaN()}
+
+func isLineBreak(pt Point) bool {
+	return math.IsNaN(pt.X) || math.IsNaN(pt.Y)
+}
+
+// splitLines splits the points at line breaks
+func splitLines(points []Point) [][]Point {
+	var (
+		res  [][]Point
+		from int
+	)
+	for n := 0; n <= len(points); n++ {
+		if n == len(points) || isLineBreak(points[n]) {
+			if n > from {
+				res = append(res, points[from:n])
+			}
+			from = n + 1
+		}
+	}
+	return res
+}
+
+// chartLine collects the chart points of track points, with line breaks between segments (if the
+// GapPolicy is not GapConnect)
+type chartLine struct {
+	policy  GapPolicy
+	points  []Point
+	segment [2]int
+	// gaps are the hatched areas for GapHatch
+	gaps []Span
+}
+
+func newChartLine(params ChartParams) *chartLine {
+	return &chartLine{policy: params.GapPolicyOrDefault()}
+}
+
+// add appends the point of a track point in the track and segment
+func (cl *chartLine) add(pt Point, trackNo, segmentNo int) {
+	segment := [2]int{trackNo, segmentNo}
+	if len(cl.points) > 0 && segment != cl.segment && cl.policy != GapConnect {
+		if prev := cl.points[len(cl.points)-1]; cl.policy == GapHatch && pt.X > prev.X {
+			cl.gaps = append(cl.gaps, Span{MinX: prev.X, MaxX: pt.X, Color: gapColor, Hatched: true})
+		}
+		cl.points = append(cl.points, lineBreak)
+	}
+	cl.segment = segment
+	cl.points = append(cl.points, pt)
+}
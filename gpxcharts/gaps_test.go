@@ -0,0 +1,86 @@
+package gpxcharts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// twoSegmentsGPX returns the profile track split in two segments, with a 1km gap between them
+func twoSegmentsGPX() gpx.GPX {
+	g := profileGPX(2, 4, 0, -3, 5, 1)
+	points := g.Tracks[0].Segments[0].Points
+	second := make([]gpx.GPXPoint, len(points)-300)
+	copy(second, points[300:])
+	for n := range second {
+		second[n].Longitude += 1000 / oneDegree
+	}
+	g.Tracks[0].Segments = []gpx.GPXTrackSegment{{Points: points[:300]}, {Points: second}}
+	return g
+}
+
+func TestSplitLines(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, splitLines(nil))
+	assert.Equal(t, [][]Point{{{0, 1}, {1, 2}}}, splitLines([]Point{{0, 1}, {1, 2}}))
+	assert.Equal(t, [][]Point{{{0, 1}}, {{2, 3}, {3, 4}}}, splitLines([]Point{{0, 1}, lineBreak, {2, 3}, {3, 4}}))
+}
+
+func TestChartLine(t *testing.T) {
+	t.Parallel()
+
+	for _, policy := range AllGapPolicies() {
+		line := newChartLine(ChartParams{GapPolicy: policy})
+		line.add(Point{0, 1}, 0, 0)
+		line.add(Point{1, 1}, 0, 0)
+		line.add(Point{5, 1}, 0, 1)
+		line.add(Point{6, 1}, 1, 0)
+		switch policy {
+		case GapConnect:
+			assert.Equal(t, 4, len(line.points))
+			assert.Empty(t, line.gaps)
+		case GapBreak:
+			assert.Equal(t, 6, len(line.points))
+			assert.True(t, isLineBreak(line.points[2]))
+			assert.Empty(t, line.gaps)
+		case GapHatch:
+			assert.Equal(t, 6, len(line.points))
+			assert.Equal(t, []Span{{MinX: 1, MaxX: 5, Color: gapColor, Hatched: true}, {MinX: 5, MaxX: 6, Color: gapColor, Hatched: true}}, line.gaps)
+		}
+	}
+	assert.Equal(t, GapConnect, ChartParams{}.GapPolicyOrDefault())
+}
+
+func TestGapDistance(t *testing.T) {
+	t.Parallel()
+
+	g := twoSegmentsGPX()
+	without := chartService.Stats(ChartParams{}, g)
+	with := chartService.Stats(ChartParams{GapDistance: true}, g)
+	assert.InDelta(t, 5990, without.Distance, 1)
+	assert.InDelta(t, 7000, with.Distance, 1)
+
+	prepared, err := chartService.elevationChartParams(ChartParams{GapPolicy: GapHatch, GapDistance: true}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(prepared.Spans)) {
+		assert.InDelta(t, 2990, prepared.Spans[0].MinX, 10)
+		assert.InDelta(t, 4000, prepared.Spans[0].MaxX, 10)
+	}
+	assert.Equal(t, len(g.Tracks[0].Segments[0].Points)+len(g.Tracks[0].Segments[1].Points)+1, len(prepared.mainPoints()))
+}
+
+func TestChartsWithGaps(t *testing.T) {
+	t.Parallel()
+
+	g := twoSegmentsGPX()
+	for _, policy := range AllGapPolicies() {
+		prepared, err := chartService.elevationChartParams(ChartParams{GapPolicy: policy, ColorByGrade: true}, g)
+		assert.Nil(t, err)
+		series := prepared.Series[0]
+		// Fill colors also for the line break:
+		assert.Equal(t, len(series.Points), len(series.FillColors), policy)
+		assert.Equal(t, policy != GapConnect, isLineBreak(series.Points[300]), policy)
+	}
+}
@@ -0,0 +1,139 @@
+package gpxcharts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+	"github.com/llgcode/draw2d/draw2dsvg"
+)
+
+// Gradient is a vertical linear gradient fill, from the top to the bottom of the chart area
+type Gradient struct {
+	Top, Bottom color.RGBA
+}
+
+// at returns the color at k (0 is the top, 1 the bottom)
+func (g Gradient) at(k float64) color.RGBA {
+	k = math.Max(0, math.Min(1, k))
+	mix := func(top, bottom uint8) uint8 {
+		return uint8(math.Round(float64(top) + k*(float64(bottom)-float64(top))))
+	}
+	return color.RGBA{mix(g.Top.R, g.Bottom.R), mix(g.Top.G, g.Bottom.G), mix(g.Top.B, g.Bottom.B), mix(g.Top.A, g.Bottom.A)}
+}
+
+// gradientFiller is implemented by graphic contexts which can fill the current path with a gradient,
+// top and bottom are image coordinates of the gradient start and end
+type gradientFiller interface {
+	fillGradient(gradient Gradient, top, bottom float64)
+}
+
+// verticalGradient is an image with the gradient colors
+type verticalGradient struct {
+	gradient    Gradient
+	top, bottom float64
+	bounds      image.Rectangle
+}
+
+func (vg verticalGradient) ColorModel() color.Model { return color.RGBAModel }
+func (vg verticalGradient) Bounds() image.Rectangle { return vg.bounds }
+func (vg verticalGradient) At(x, y int) color.Color {
+	if vg.bottom <= vg.top {
+		return vg.gradient.Top
+	}
+	return vg.gradient.at((float64(y) + 0.5 - vg.top) / (vg.bottom - vg.top))
+}
+
+// imgGraphicContext rasterizes gradients, the path is rendered as a mask for the gradient image
+type imgGraphicContext struct {
+	*draw2dimg.GraphicContext
+	img *image.RGBA
+}
+
+func newImgGraphicContext(img *image.RGBA) *imgGraphicContext {
+	return &imgGraphicContext{GraphicContext: draw2dimg.NewGraphicContext(img), img: img}
+}
+
+func (gc *imgGraphicContext) fillGradient(gradient Gradient, top, bottom float64) {
+	mask := image.NewRGBA(gc.img.Bounds())
+	maskGc := draw2dimg.NewGraphicContext(mask)
+	path := gc.GetPath()
+	maskGc.SetFillColor(color.Opaque)
+	maskGc.Fill(&path)
+	gc.BeginPath()
+	src := verticalGradient{gradient: gradient, top: top, bottom: bottom, bounds: gc.img.Bounds()}
+	draw.DrawMask(gc.img, gc.img.Bounds(), src, image.Point{}, mask, image.Point{}, draw.Over)
+}
+
+// svgGraphicContext fills paths with <linearGradient> elements, which are added to the SVG in marshal
+type svgGraphicContext struct {
+	*draw2dsvg.GraphicContext
+	svg       *draw2dsvg.Svg
+	gradients []svgLinearGradient
+}
+
+type svgGradientStop struct {
+	Offset  string  `xml:"offset,attr"`
+	Color   string  `xml:"stop-color,attr"`
+	Opacity float64 `xml:"stop-opacity,attr"`
+}
+
+type svgLinearGradient struct {
+	XMLName xml.Name          `xml:"linearGradient"`
+	ID      string            `xml:"id,attr"`
+	Units   string            `xml:"gradientUnits,attr"`
+	X1      float64           `xml:"x1,attr"`
+	Y1      float64           `xml:"y1,attr"`
+	X2      float64           `xml:"x2,attr"`
+	Y2      float64           `xml:"y2,attr"`
+	Stops   []svgGradientStop `xml:"stop"`
+}
+
+func newSvgGraphicContext(svg *draw2dsvg.Svg) *svgGraphicContext {
+	return &svgGraphicContext{GraphicContext: draw2dsvg.NewGraphicContext(svg), svg: svg}
+}
+
+func newSvgGradientStop(offset string, c color.RGBA) svgGradientStop {
+	// Stop colors are not alpha premultiplied:
+	nrgba := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return svgGradientStop{
+		Offset:  offset,
+		Color:   fmt.Sprintf("#%02x%02x%02x", nrgba.R, nrgba.G, nrgba.B),
+		Opacity: float64(nrgba.A) / 0xff,
+	}
+}
+
+func (gc *svgGraphicContext) fillGradient(gradient Gradient, top, bottom float64) {
+	gc.Fill()
+	id := fmt.Sprintf("gradient%d", len(gc.gradients)+1)
+	gc.svg.Groups[len(gc.svg.Groups)-1].Fill = "url(#" + id + ")"
+	gc.gradients = append(gc.gradients, svgLinearGradient{
+		ID:    id,
+		Units: "userSpaceOnUse",
+		Y1:    top,
+		Y2:    bottom,
+		Stops: []svgGradientStop{newSvgGradientStop("0", gradient.Top), newSvgGradientStop("1", gradient.Bottom)},
+	})
+}
+
+// marshal marshals the SVG, with gradients in <defs> after the <svg> start tag
+func (gc *svgGraphicContext) marshal() ([]byte, error) {
+	byts, err := xml.Marshal(gc.svg)
+	if err != nil || len(gc.gradients) == 0 {
+		return byts, err
+	}
+	defs, err := xml.Marshal(struct {
+		XMLName   xml.Name `xml:"defs"`
+		Gradients []svgLinearGradient
+	}{Gradients: gc.gradients})
+	if err != nil {
+		return nil, err
+	}
+	pos := bytes.IndexByte(byts, '>') + 1
+	return append(byts[:pos:pos], append(defs, byts[pos:]...)...), nil
+}
@@ -0,0 +1,97 @@
+package gpxcharts
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGradientAt(t *testing.T) {
+	t.Parallel()
+
+	gradient := Gradient{Top: color.RGBA{0xff, 0, 0, 0xff}, Bottom: color.RGBA{0, 0, 0, 0}}
+	assert.Equal(t, gradient.Top, gradient.at(0))
+	assert.Equal(t, gradient.Bottom, gradient.at(1))
+	assert.Equal(t, gradient.Bottom, gradient.at(2))
+	assert.Equal(t, color.RGBA{0x80, 0, 0, 0x80}, gradient.at(0.5))
+}
+
+func TestTransparentBackground(t *testing.T) {
+	t.Parallel()
+
+	params := ChartParams{
+		Width:                 200,
+		Height:                100,
+		MinX:                  0,
+		MaxX:                  1,
+		MinY:                  0,
+		MaxY:                  1,
+		TransparentBackground: true,
+		Series:                []Series{{Points: []Point{{0, 0.2}, {1, 0.5}}, Fill: true}},
+	}
+	byts, err := chartService.Chart(context.Background(), params, OutputPNG)
+	assert.Nil(t, err)
+	img, err := png.Decode(bytes.NewReader(byts))
+	if assert.Nil(t, err) {
+		_, _, _, a := img.At(100, 5).RGBA()
+		assert.Equal(t, uint32(0), a)
+		_, _, _, a = img.At(100, 95).RGBA()
+		assert.NotEqual(t, uint32(0), a)
+	}
+
+	byts, err = chartService.Chart(context.Background(), params, OutputSVG)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(byts), "#FFFFFF"))
+
+	// Transparent theme background:
+	theme, err := ParseTheme([]byte(`{"background": "00000000"}`))
+	assert.Nil(t, err)
+	params.TransparentBackground = false
+	params.Theme = theme
+	byts, err = chartService.Chart(context.Background(), params, OutputPNG)
+	assert.Nil(t, err)
+	img, err = png.Decode(bytes.NewReader(byts))
+	if assert.Nil(t, err) {
+		_, _, _, a := img.At(100, 5).RGBA()
+		assert.Equal(t, uint32(0), a)
+	}
+}
+
+func TestGradientFill(t *testing.T) {
+	t.Parallel()
+
+	gradient := &Gradient{Top: color.RGBA{0x20, 0x60, 0xd0, 0xff}, Bottom: color.RGBA{0x10, 0x30, 0x68, 0x80}}
+	params := ChartParams{
+		Width:                 200,
+		Height:                100,
+		MinX:                  0,
+		MaxX:                  1,
+		MinY:                  0,
+		MaxY:                  1,
+		FillGradient:          gradient,
+		TransparentBackground: true,
+		Series:                []Series{{Points: []Point{{0, 1}, {1, 1}}, Fill: true}},
+	}
+	byts, err := chartService.Chart(context.Background(), params, OutputPNG)
+	assert.Nil(t, err)
+	img, err := png.Decode(bytes.NewReader(byts))
+	if assert.Nil(t, err) {
+		top := color.RGBAModel.Convert(img.At(100, 2)).(color.RGBA)
+		bottom := color.RGBAModel.Convert(img.At(100, 97)).(color.RGBA)
+		assert.InDelta(t, 0xd0, top.B, 0x10)
+		assert.True(t, bottom.A < top.A)
+	}
+
+	byts, err = chartService.Chart(context.Background(), params, OutputSVG)
+	assert.Nil(t, err)
+	svg := string(byts)
+	assert.True(t, strings.Contains(svg, "<linearGradient id=\"gradient1\""))
+	assert.True(t, strings.Contains(svg, "url(#gradient1)"))
+	assert.Nil(t, xml.Unmarshal(byts, new(struct{})))
+}
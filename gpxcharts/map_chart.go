@@ -0,0 +1,204 @@
+package gpxcharts
+
+import (
+	"context"
+	"image/color"
+	"math"
+
+	"github.com/llgcode/draw2d"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Meters per degree of latitude (same as in gpxgo's distance computation)
+const oneDegree = 1000.0 * 10000.8 / 90.0
+
+var (
+	mapTrackColor  = color.RGBA{0x20, 0x50, 0xd0, 0xff}
+	mapStartColor  = color.RGBA{0x20, 0xa0, 0x20, 0xff}
+	mapFinishColor = color.RGBA{0xd0, 0x20, 0x20, 0xff}
+)
+
+// mapProjection is a local equirectangular projection (in meters) centered
+// on the track bounds, good enough for tracks up to a few hundred kilometers.
+type mapProjection struct {
+	lat0, lon0 float64
+	cosLat0    float64
+}
+
+func newMapProjection(bounds gpx.GpxBounds) mapProjection {
+	lat0 := (bounds.MinLatitude + bounds.MaxLatitude) / 2
+	return mapProjection{
+		lat0:    lat0,
+		lon0:    (bounds.MinLongitude + bounds.MaxLongitude) / 2,
+		cosLat0: math.Cos(lat0 * math.Pi / 180),
+	}
+}
+
+func (mp mapProjection) project(latitude, longitude float64) Point {
+	return Point{
+		X: (longitude - mp.lon0) * oneDegree * mp.cosLat0,
+		Y: (latitude - mp.lat0) * oneDegree,
+	}
+}
+
+// fitAspectRatio sets the chart bounds so that one meter has the same length
+// in pixels on both axes, with the track centered.
+func fitAspectRatio(params *ChartParams, minX, maxX, minY, maxY float64) {
+	width := float64(params.Width) - params.ChartMargin.Left - params.ChartMargin.Right
+	height := float64(params.Height) - params.ChartMargin.Top - params.ChartMargin.Bottom
+	if width <= 0 || height <= 0 {
+		return
+	}
+	// Leave some space for the start/finish markers:
+	metersPerPixel := 1.1 * math.Max((maxX-minX)/width, (maxY-minY)/height)
+	if metersPerPixel <= 0 {
+		metersPerPixel = 1
+	}
+	centerX, centerY := (minX+maxX)/2, (minY+maxY)/2
+	params.MinX, params.MaxX = centerX-metersPerPixel*width/2, centerX+metersPerPixel*width/2
+	params.MinY, params.MaxY = centerY-metersPerPixel*height/2, centerY+metersPerPixel*height/2
+}
+
+// mapChartParams prepares the projected track lines (Series in params are ignored, their points are not
+// map coordinates)
+func (cs ChartService) mapChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return params, err
+	}
+	params.XAxis.Show = false
+	params.YAxis.Show = false
+	params.ChartPadding = Padding{}
+	params.Series = nil
+
+	if g.GetTrackPointsNo() == 0 {
+		return params, nil
+	}
+
+	mp := newMapProjection(g.Bounds())
+	var (
+		first, last *Point
+		lines       []Series
+	)
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			if len(segment.Points) == 0 {
+				continue
+			}
+			line := Series{Color: mapTrackColor}
+			for _, pt := range segment.Points {
+				point := mp.project(pt.Latitude, pt.Longitude)
+				line.Points = append(line.Points, point)
+				if first == nil {
+					first = &point
+				}
+				last = &point
+			}
+			lines = append(lines, line)
+		}
+	}
+	params.Series = lines
+	params.Markers = append(params.Markers, Marker{X: last.X, Y: last.Y, Color: mapFinishColor}, Marker{X: first.X, Y: first.Y, Color: mapStartColor})
+
+	minX, maxX, minY, maxY := math.MaxFloat64, -math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64
+	for _, series := range params.Series {
+		for _, point := range series.Points {
+			minX, maxX = math.Min(minX, point.X), math.Max(maxX, point.X)
+			minY, maxY = math.Min(minY, point.Y), math.Max(maxY, point.Y)
+		}
+	}
+	fitAspectRatio(&params, minX, maxX, minY, maxY)
+	return params, nil
+}
+
+// MapChart draws the track outline (without map tiles). Series in params are ignored.
+func (cs ChartService) MapChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.mapChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
+	if len(params.Series) == 0 {
+		return cs.chart(c, params, output)
+	}
+	return cs.draw(c, params, output, cs.renderMap)
+}
+
+func (cs ChartService) renderMap(c context.Context, params ChartParams, gc draw2d.GraphicContext) {
+	cs.renderChart(c, params, gc)
+
+	font := params.annotationFont()
+	fontSize := font.Size
+	lineWidth := math.Max(params.LineWidth, 0.5)
+	textColor := params.ThemeOrDefault().Label
+
+	if params.ScaleBar {
+		length, label := scaleBarLength((params.MaxX-params.MinX)/4, params.UnitTypeOrMetric())
+		x1, y := params.toImgCoords(params.MinX, params.MinY)
+		x1, y = x1+10, y-10
+		x2, _ := params.toImgCoords(params.MinX+length, params.MinY)
+		x2 += 10
+		gc.BeginPath()
+		gc.SetStrokeColor(textColor)
+		gc.SetLineWidth(lineWidth * 2)
+		gc.MoveTo(x1, y-4)
+		gc.LineTo(x1, y)
+		gc.LineTo(x2, y)
+		gc.LineTo(x2, y-4)
+		gc.Stroke()
+		setFont(gc, font)
+		gc.SetFillColor(textColor)
+		gc.FillStringAt(label, x1+3, y-3)
+	}
+
+	if params.NorthArrow {
+		x, y := params.toImgCoords(params.MaxX, params.MaxY)
+		x, y = x-10-fontSize/2, y+10
+		gc.BeginPath()
+		gc.SetStrokeColor(textColor)
+		gc.SetFillColor(textColor)
+		gc.SetLineWidth(lineWidth)
+		gc.MoveTo(x, y)
+		gc.LineTo(x+fontSize/2, y+2*fontSize)
+		gc.LineTo(x, y+1.5*fontSize)
+		gc.LineTo(x-fontSize/2, y+2*fontSize)
+		gc.Close()
+		gc.FillStroke()
+		setFont(gc, font)
+		gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+		textWidth := gc.FillStringAt("N", x, y)
+		gc.SetFillColor(textColor)
+		gc.FillStringAt("N", x-textWidth/2, y+3*fontSize+2)
+	}
+}
+
+// scaleBarLength returns the longest "round" length (1, 2 or 5 times a power
+// of ten in the display unit) not longer than maxLength (in meters), and its label.
+func scaleBarLength(maxLength float64, unitType UnitType) (float64, string) {
+	unit, unitName := 1., "m"
+	switch unitType {
+	case UnitTypeImperial:
+		if maxLength < ONE_MILE {
+			unit, unitName = ONE_FEET, "ft"
+		} else {
+			unit, unitName = ONE_MILE, "mi"
+		}
+	case UnitTypeNautical:
+		unit, unitName = ONE_NAUTICAL_MILE, "NM"
+	default:
+		if maxLength >= 1000 {
+			unit, unitName = 1000, "km"
+		}
+	}
+	if maxLength <= 0 {
+		return unit, "1" + unitName
+	}
+	n := maxLength / unit
+	pow := math.Pow(10, math.Floor(math.Log10(n)))
+	nice := pow
+	for _, f := range []float64{2, 5} {
+		if f*pow <= n {
+			nice = f * pow
+		}
+	}
+	return nice * unit, FormatFloat(nice, 2) + unitName
+}
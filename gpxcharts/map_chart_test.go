@@ -0,0 +1,70 @@
+package gpxcharts
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestMapAspectRatio(t *testing.T) {
+	t.Parallel()
+
+	params := ChartParams{Width: 300, Height: 200, ChartMargin: Padding{Left: 40, Bottom: 20}}
+	fitAspectRatio(&params, 0, 1000, 0, 100)
+	x1, y1 := params.toImgCoords(0, 0)
+	x2, y2 := params.toImgCoords(100, 100)
+	assert.InDelta(t, x2-x1, y1-y2, 0.001)
+	assert.True(t, params.MinX < 0 && params.MaxX > 1000)
+	assert.InDelta(t, 50, (params.MinY+params.MaxY)/2, 0.001)
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	assert.Nil(t, err)
+	mp := newMapProjection(g.Bounds())
+	pt1, pt2 := g.Tracks[0].Segments[0].Points[0], g.Tracks[0].Segments[0].Points[100]
+	p1, p2 := mp.project(pt1.Latitude, pt1.Longitude), mp.project(pt2.Latitude, pt2.Longitude)
+	assert.InEpsilon(t, pt1.Distance2D(&pt2), math.Hypot(p2.X-p1.X, p2.Y-p1.Y), 0.01)
+}
+
+func TestMapChartIgnoresSeries(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	planned := Series{Points: []Point{{0, 500}, {1e6, 800}}, Label: "planned"}
+	params, err := chartService.mapChartParams(ChartParams{Width: 300, Height: 300, Series: []Series{planned}}, *g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(params.Series)) {
+		assert.Equal(t, g.GetTrackPointsNo(), len(params.Series[0].Points))
+		assert.Equal(t, mapTrackColor, params.Series[0].Color)
+	}
+	assert.True(t, params.MaxX-params.MinX < 1e5)
+
+	params, err = chartService.mapChartParams(ChartParams{Series: []Series{planned}}, gpx.GPX{})
+	assert.Nil(t, err)
+	assert.Empty(t, params.Series)
+}
+
+func TestScaleBarLength(t *testing.T) {
+	t.Parallel()
+
+	for _, data := range []struct {
+		maxLength float64
+		unitType  UnitType
+		expected  string
+	}{
+		{maxLength: 2600, unitType: UnitTypeMetric, expected: "2km"},
+		{maxLength: 740, unitType: UnitTypeMetric, expected: "500m"},
+		{maxLength: 120, unitType: UnitTypeMetric, expected: "100m"},
+		{maxLength: 3 * ONE_MILE, unitType: UnitTypeImperial, expected: "2mi"},
+		{maxLength: 300, unitType: UnitTypeImperial, expected: "500ft"},
+		{maxLength: 0.15 * ONE_NAUTICAL_MILE, unitType: UnitTypeNautical, expected: "0.1NM"},
+	} {
+		length, label := scaleBarLength(data.maxLength, data.unitType)
+		assert.Equal(t, data.expected, label)
+		assert.True(t, length <= data.maxLength, label)
+	}
+}
@@ -0,0 +1,111 @@
+package gpxcharts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+var overlayColors = []color.RGBA{
+	{0x1f, 0x77, 0xb4, 0xff},
+	{0xff, 0x7f, 0x0e, 0xff},
+	{0x2c, 0xa0, 0x2c, 0xff},
+	{0xd6, 0x27, 0x28, 0xff},
+	{0x94, 0x67, 0xbd, 0xff},
+	{0x8c, 0x56, 0x4b, 0xff},
+	{0xe3, 0x77, 0xc2, 0xff},
+	{0x7f, 0x7f, 0x7f, 0xff},
+}
+
+// GPXName returns the GPX name, or the name of the first track with a name (empty if not found)
+func GPXName(g gpx.GPX) string {
+	if g.Name != "" {
+		return g.Name
+	}
+	for _, track := range g.Tracks {
+		if track.Name != "" {
+			return track.Name
+		}
+	}
+	return ""
+}
+
+// gpxName returns GPXName, or "#n" (counting from 1) if the GPX has no name
+func gpxName(g gpx.GPX, n int) string {
+	if name := GPXName(g); name != "" {
+		return name
+	}
+	return fmt.Sprintf("#%d", n+1)
+}
+
+// OverlayChart draws the chart type of more GPX files on the same axes, every
+// one with a different color and with names (from <name>) in the legend.
+func (cs ChartService) OverlayChart(c context.Context, params ChartParams, typ ChartType, gpxs []gpx.GPX, output OutputExtension) ([]byte, error) {
+	extended := make([]ExtendedGPX, len(gpxs))
+	for n := range gpxs {
+		extended[n] = ExtendedGPX{GPX: gpxs[n]}
+	}
+	return cs.OverlayExtendedChart(c, params, typ, extended, output)
+}
+
+// OverlayExtendedChart is OverlayChart with track point extensions (needed for extension chart types)
+func (cs ChartService) OverlayExtendedChart(c context.Context, params ChartParams, typ ChartType, gpxs []ExtendedGPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.overlayChartParams(params, typ, gpxs)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+func (cs ChartService) overlayChartParams(params ChartParams, typ ChartType, gpxs []ExtendedGPX) (ChartParams, error) {
+	if len(gpxs) == 0 {
+		return params, errors.New("no GPX to chart")
+	}
+
+	params.ColorByGrade = false
+	typeParams := params
+	typeParams.Series = nil
+	var (
+		series                     []Series
+		maxXRange, maxYRange       = -1., -1.
+		xAxis, yAxis               Axis
+		explicitMinY, explicitMaxY = math.MaxFloat64, -math.MaxFloat64
+	)
+	for n, g := range gpxs {
+		gpxParams, err := cs.chartTypeParams(typ, typeParams, g)
+		if err != nil {
+			return params, err
+		}
+		if points := gpxParams.mainPoints(); len(points) > 0 {
+			minX, maxX := points[0].X, points[len(points)-1].X
+			minY, maxY := minMaxY(points)
+			// The axis (grid, labels) prepared for the biggest range is used for all:
+			if maxX-minX > maxXRange {
+				maxXRange, xAxis = maxX-minX, gpxParams.XAxis
+			}
+			if maxY-minY > maxYRange {
+				maxYRange, yAxis = maxY-minY, gpxParams.YAxis
+			}
+		}
+		if gpxParams.MinY != params.MinY || gpxParams.MaxY != params.MaxY {
+			// Y range set by the chart type (for example steepness):
+			explicitMinY, explicitMaxY = math.Min(explicitMinY, gpxParams.MinY), math.Max(explicitMaxY, gpxParams.MaxY)
+		}
+
+		lineColor := overlayColors[n%len(overlayColors)]
+		series = append(series, Series{Points: gpxParams.mainPoints(), Color: lineColor, Label: gpxName(g.GPX, n)})
+	}
+
+	if maxXRange >= 0 {
+		params.XAxis, params.YAxis = xAxis, yAxis
+	}
+	if explicitMinY < explicitMaxY {
+		params.MinY, params.MaxY = explicitMinY, explicitMaxY
+	}
+	params.Series = append(series, params.Series...)
+	return params, nil
+}
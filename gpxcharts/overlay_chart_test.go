@@ -0,0 +1,50 @@
+package gpxcharts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestOverlayChart(t *testing.T) {
+	t.Parallel()
+
+	var gpxs []gpx.GPX
+	for _, fn := range []string{"../test_files/zbevnica.gpx", "../test_files/parenzana.gpx", "../test_files/empty.gpx"} {
+		g, err := gpx.ParseFile(fn)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		gpxs = append(gpxs, *g)
+	}
+
+	extended := make([]ExtendedGPX, len(gpxs))
+	for n := range gpxs {
+		extended[n] = ExtendedGPX{GPX: gpxs[n]}
+	}
+	for _, typ := range AllChartTypes() {
+		params, err := chartService.overlayChartParams(ChartParams{}, typ, extended)
+		assert.Nil(t, err)
+		if assert.Equal(t, len(gpxs), len(params.Series), typ) {
+			for n, series := range params.Series {
+				single, err := chartService.chartTypeParams(typ, ChartParams{}, extended[n])
+				assert.Nil(t, err)
+				assert.Equal(t, single.mainPoints(), series.Points, typ)
+				assert.Equal(t, overlayColors[n], series.Color)
+				assert.Equal(t, gpxName(gpxs[n], n), series.Label)
+			}
+		}
+	}
+
+	_, err := chartService.OverlayChart(context.Background(), ChartParams{Width: 900, Height: 200}, ChartTypeElevation, nil, OutputPNG)
+	assert.NotNil(t, err)
+
+	assert.Equal(t, "", GPXName(gpxs[2]))
+	assert.Equal(t, "#3", gpxName(gpxs[2], 2))
+	gpxs[2].Tracks = append(gpxs[2].Tracks, gpx.GPXTrack{Name: "track name"})
+	assert.Equal(t, "track name", gpxName(gpxs[2], 2))
+	gpxs[2].Name = "name"
+	assert.Equal(t, "name", gpxName(gpxs[2], 2))
+}
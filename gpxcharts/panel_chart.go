@@ -0,0 +1,72 @@
+package gpxcharts
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	"github.com/llgcode/draw2d"
+)
+
+// Vertical space between panels (in pixels)
+const panelGap = 10
+
+// panelsParams prepares the params for every panel, all with the same X axis
+// and left/right margins. Only the bottom panel has X axis labels. Series in params
+// are ignored (they can't be drawn with the Y axes of all panels).
+func (cs ChartService) panelsParams(params ChartParams, g ExtendedGPX, types []ChartType) ([]ChartParams, error) {
+	if len(types) == 0 {
+		return nil, errors.New("no chart types")
+	}
+
+	params.Series = nil
+	var panels []ChartParams
+	minX, maxX := math.MaxFloat64, -math.MaxFloat64
+	for _, typ := range types {
+		panel, err := cs.chartTypeParams(typ, params, g)
+		if err != nil {
+			return nil, err
+		}
+		for _, series := range panel.Series {
+			for _, point := range series.Points {
+				if isLineBreak(point) {
+					continue
+				}
+				minX, maxX = math.Min(minX, point.X), math.Max(maxX, point.X)
+			}
+		}
+		panel.Title = typ.Name()
+		panels = append(panels, panel)
+	}
+
+	top, bottom := params.ChartMargin.Top, params.ChartMargin.Bottom
+	panelHeight := (float64(params.Height) - top - bottom - panelGap*float64(len(panels)-1)) / float64(len(panels))
+	for n := range panels {
+		panel := &panels[n]
+		if params.MinX == 0 && params.MaxX == 0 && minX < maxX {
+			panel.MinX, panel.MaxX = minX, maxX
+		}
+		panel.ChartMargin.Top = top + float64(n)*(panelHeight+panelGap)
+		panel.ChartMargin.Bottom = float64(params.Height) - panel.ChartMargin.Top - panelHeight
+		panel.noBackground = true
+		if n < len(panels)-1 {
+			panel.XAxis.Labels = 0
+		}
+	}
+	return panels, nil
+}
+
+// PanelChart renders more chart types in vertically stacked panels, with a shared X axis. Series
+// in params are ignored.
+func (cs ChartService) PanelChart(c context.Context, params ChartParams, g ExtendedGPX, types []ChartType, output OutputExtension) ([]byte, error) {
+	panels, err := cs.panelsParams(params, g, types)
+	if err != nil {
+		return nil, err
+	}
+	return cs.draw(c, params, output, func(c context.Context, params ChartParams, gc draw2d.GraphicContext) {
+		cs.renderBackground(params, gc)
+		for _, panel := range panels {
+			cs.renderChart(c, panel, gc)
+		}
+	})
+}
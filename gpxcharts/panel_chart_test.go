@@ -0,0 +1,53 @@
+package gpxcharts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPanelChart(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	params := ChartParams{Width: 900, Height: 400, ChartMargin: Padding{Left: 40, Bottom: 20}, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}}
+	types := []ChartType{ChartTypeElevation, ChartTypeSpeed, ChartTypeSteepness, ExtensionChartType("gpxtpx:hr")}
+	panels, err := chartService.panelsParams(params, *g, types)
+	assert.Nil(t, err)
+	assert.Equal(t, len(types), len(panels))
+	for n, panel := range panels {
+		assert.Equal(t, panels[0].MinX, panel.MinX)
+		assert.Equal(t, panels[0].MaxX, panel.MaxX)
+		assert.Equal(t, params.ChartMargin.Left, panel.ChartMargin.Left)
+		assert.Equal(t, n == len(panels)-1, panel.XAxis.Labels > 0)
+		if n > 0 {
+			previousBottom := float64(params.Height) - panels[n-1].ChartMargin.Bottom
+			assert.InDelta(t, panelGap, panel.ChartMargin.Top-previousBottom, 0.001)
+		}
+	}
+	assert.Equal(t, params.ChartMargin.Bottom, panels[len(panels)-1].ChartMargin.Bottom)
+
+	// Series in params are ignored:
+	withSeries := params
+	withSeries.Series = []Series{{Points: []Point{{-1e6, 0}, {1e6, 0}}, Label: "planned"}}
+	ignored, err := chartService.panelsParams(withSeries, *g, types)
+	assert.Nil(t, err)
+	for n, panel := range ignored {
+		assert.Equal(t, len(panels[n].Series), len(panel.Series))
+		assert.Equal(t, panels[n].MinX, panel.MinX)
+		assert.Equal(t, panels[n].MaxX, panel.MaxX)
+		for _, series := range panel.Series {
+			assert.NotEqual(t, "planned", series.Label)
+		}
+	}
+
+	_, err = chartService.PanelChart(context.Background(), params, *g, nil, OutputPNG)
+	assert.NotNil(t, err)
+	_, err = chartService.PanelChart(context.Background(), params, *g, []ChartType{ChartTypeElevation, "invalid"}, OutputPNG)
+	assert.NotNil(t, err)
+}
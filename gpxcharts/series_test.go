@@ -0,0 +1,62 @@
+package gpxcharts
+
+import (
+	"context"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestSeriesLegend(t *testing.T) {
+	t.Parallel()
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	params := ChartParams{
+		Legend: []LegendItem{{Label: "first"}},
+		Series: []Series{
+			{Points: []Point{{0, 1}}, Fill: true, FillColor: red, Label: "filled"},
+			{Points: []Point{{0, 2}}, Color: red, Label: "line"},
+			{Points: []Point{{0, 3}}},
+		},
+	}
+	assert.Equal(t, []LegendItem{{Label: "first"}, {Color: red, Label: "filled"}, {Color: red, Label: "line"}}, params.legend())
+	assert.Equal(t, 1, len(params.Legend))
+	assert.Equal(t, []Point{{0, 1}}, params.mainPoints())
+	assert.Equal(t, defaultFillColor, params.Series[2].fillColor(params, positive))
+}
+
+func TestSeriesOnElevationChart(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	// Planned elevation (actual + 50m), drawn over the track elevation:
+	actual, err := chartService.elevationChartParams(ChartParams{}, *g)
+	assert.Nil(t, err)
+	var planned []Point
+	for _, pt := range actual.mainPoints() {
+		planned = append(planned, Point{pt.X, pt.Y + 50})
+	}
+	plannedSeries := Series{Points: planned, Color: color.RGBA{0x20, 0x60, 0xd0, 0xff}, Dash: []float64{6, 3}, Label: "planned"}
+
+	params := ChartParams{Width: 900, Height: 250, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}, Series: []Series{plannedSeries}}
+	prepared, err := chartService.elevationChartParams(params, *g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(prepared.Series)) {
+		assert.True(t, prepared.Series[0].Fill)
+		assert.Equal(t, plannedSeries, prepared.Series[1])
+	}
+	actual.prepare()
+	prepared.prepare()
+	assert.True(t, prepared.MaxY >= actual.MaxY+50)
+
+	byts, err := chartService.ElevationChart(context.Background(), params, *g, OutputSVG)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(byts), "stroke-dasharray"))
+}
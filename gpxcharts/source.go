@@ -0,0 +1,108 @@
+package gpxcharts
+
+import (
+	"fmt"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Source is the part of the GPX to chart
+type Source string
+
+const (
+	// SourceAuto uses tracks, or routes if there are no track points
+	SourceAuto  Source = ""
+	SourceTrack Source = "track"
+	SourceRoute Source = "route"
+)
+
+func AllSources() []Source {
+	return []Source{
+		SourceTrack,
+		SourceRoute,
+	}
+}
+
+func hasTrackPoints(g gpx.GPX) bool {
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			if len(segment.Points) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectSource returns the GPX with only the chosen tracks. With SourceRoute (or SourceAuto without
+// track points) the routes are converted to single segment tracks. The no is the number (starting
+// with 1) of the track or route, 0 for all of them.
+func SelectSource(g gpx.GPX, source Source, no int) (gpx.GPX, error) {
+	if source == SourceAuto {
+		source = SourceTrack
+		if !hasTrackPoints(g) && len(g.Routes) > 0 {
+			source = SourceRoute
+		}
+	}
+
+	var tracks []gpx.GPXTrack
+	switch source {
+	case SourceTrack:
+		tracks = g.Tracks
+	case SourceRoute:
+		for _, route := range g.Routes {
+			tracks = append(tracks, gpx.GPXTrack{
+				Name:        route.Name,
+				Comment:     route.Comment,
+				Description: route.Description,
+				Source:      route.Source,
+				Number:      route.Number,
+				Type:        route.Type,
+				Segments:    []gpx.GPXTrackSegment{{Points: route.Points}},
+			})
+		}
+	default:
+		return g, fmt.Errorf("invalid source: %s", source)
+	}
+
+	if no < 0 || no > len(tracks) {
+		return g, fmt.Errorf("no %s #%d, found %d", source, no, len(tracks))
+	}
+	if no > 0 {
+		tracks = tracks[no-1 : no]
+	}
+	g.Tracks = tracks
+	g.Routes = nil
+	return g, nil
+}
+
+// SelectSource is SelectSource with track point extensions (routes have no extensions)
+func (eg ExtendedGPX) SelectSource(source Source, no int) (ExtendedGPX, error) {
+	g, err := SelectSource(eg.GPX, source, no)
+	if err != nil {
+		return eg, err
+	}
+	var ext [][][]PointExtensions
+	if source == SourceTrack || (source == SourceAuto && hasTrackPoints(eg.GPX)) {
+		ext = eg.TrackExtensions
+		if no > 0 && no <= len(ext) {
+			ext = ext[no-1 : no]
+		}
+	}
+	return ExtendedGPX{GPX: g, TrackExtensions: ext}, nil
+}
+
+// selectSource applies Source and SourceNo, the source selection is removed from the returned params
+// (so that it is not applied again)
+func (cp ChartParams) selectSource(g gpx.GPX) (ChartParams, gpx.GPX, error) {
+	g, err := SelectSource(g, cp.Source, cp.SourceNo)
+	cp.Source, cp.SourceNo = SourceAuto, 0
+	return cp, g, err
+}
+
+// selectExtendedSource is selectSource with track point extensions
+func (cp ChartParams) selectExtendedSource(g ExtendedGPX) (ChartParams, ExtendedGPX, error) {
+	g, err := g.SelectSource(cp.Source, cp.SourceNo)
+	cp.Source, cp.SourceNo = SourceAuto, 0
+	return cp, g, err
+}
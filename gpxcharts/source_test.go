@@ -0,0 +1,125 @@
+package gpxcharts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// routeOnly returns the GPX with every track segment converted to a route
+func routeOnly(g gpx.GPX) gpx.GPX {
+	res := gpx.GPX{Name: g.Name}
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			res.Routes = append(res.Routes, gpx.GPXRoute{Name: track.Name, Points: segment.Points})
+		}
+	}
+	return res
+}
+
+func TestSelectSource(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	pointsNo := g.GetTrackPointsNo()
+
+	selected, err := SelectSource(*g, SourceAuto, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, pointsNo, selected.GetTrackPointsNo())
+
+	routes := routeOnly(*g)
+	routes.Routes = append(routes.Routes, gpx.GPXRoute{Name: "second", Points: g.Tracks[0].Segments[0].Points[:10]})
+	for _, source := range []Source{SourceAuto, SourceRoute} {
+		selected, err = SelectSource(routes, source, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, pointsNo+10, selected.GetTrackPointsNo())
+		assert.Empty(t, selected.Routes)
+
+		selected, err = SelectSource(routes, source, 2)
+		assert.Nil(t, err)
+		if assert.Equal(t, 1, len(selected.Tracks)) {
+			assert.Equal(t, "second", selected.Tracks[0].Name)
+			assert.Equal(t, 10, selected.GetTrackPointsNo())
+		}
+	}
+
+	selected, err = SelectSource(routes, SourceTrack, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, selected.GetTrackPointsNo())
+
+	_, err = SelectSource(routes, SourceRoute, 3)
+	assert.NotNil(t, err)
+	_, err = SelectSource(routes, "invalid", 0)
+	assert.NotNil(t, err)
+
+	eg, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	selectedExt, err := eg.SelectSource(SourceTrack, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, eg.TrackExtensions, selectedExt.TrackExtensions)
+	selectedExt, err = eg.SelectSource(SourceRoute, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, selectedExt.TrackExtensions)
+}
+
+func TestRouteCharts(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	routes := routeOnly(*g)
+
+	trackParams, err := chartService.elevationChartParams(ChartParams{}, *g)
+	assert.Nil(t, err)
+	routeParams, err := chartService.elevationChartParams(ChartParams{}, routes)
+	assert.Nil(t, err)
+	assert.Equal(t, trackParams.Series, routeParams.Series)
+
+	_, err = chartService.steepnessChartParams(ChartParams{Source: SourceRoute, SourceNo: 2}, routes)
+	assert.NotNil(t, err)
+
+	prepared, err := chartService.steepnessChartParams(ChartParams{Source: SourceRoute, SourceNo: 1}, routes)
+	assert.Nil(t, err)
+	expected, err := chartService.steepnessChartParams(ChartParams{}, *g)
+	assert.Nil(t, err)
+	assert.Equal(t, expected.Series, prepared.Series)
+}
+
+func TestSourceInDirectCharts(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	mixed := *g
+	mixed.Routes = []gpx.GPXRoute{{Points: g.Tracks[0].Segments[0].Points[:100]}}
+
+	trackParams, err := chartService.speedChartParams(ChartParams{}, mixed)
+	assert.Nil(t, err)
+	routeParams, err := chartService.speedChartParams(ChartParams{Source: SourceRoute}, mixed)
+	assert.Nil(t, err)
+	trackPoints, routePoints := trackParams.mainPoints(), routeParams.mainPoints()
+	assert.Less(t, routePoints[len(routePoints)-1].X, trackPoints[len(trackPoints)-1].X/2)
+
+	invalid := ChartParams{Width: 900, Height: 200, Source: SourceRoute, SourceNo: 2}
+	_, err = chartService.SpeedChart(context.Background(), invalid, mixed, OutputPNG)
+	assert.NotNil(t, err)
+	_, err = chartService.PaceChart(context.Background(), invalid, mixed, OutputPNG)
+	assert.NotNil(t, err)
+	_, err = chartService.VerticalSpeedChart(context.Background(), invalid, mixed, OutputPNG)
+	assert.NotNil(t, err)
+	_, err = chartService.AscentDescentChart(context.Background(), invalid, mixed, OutputPNG)
+	assert.NotNil(t, err)
+	_, err = chartService.AscentDescentChart(context.Background(), ChartParams{Width: 900, Height: 200, Source: SourceRoute, SourceNo: 1}, mixed, OutputPNG)
+	assert.Nil(t, err)
+}
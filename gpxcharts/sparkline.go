@@ -0,0 +1,50 @@
+package gpxcharts
+
+import "math"
+
+// Default sparkline (thumbnail) size
+const (
+	SparklineWidth  = 120
+	SparklineHeight = 24
+)
+
+// sparklineDotRadius is the radius of the min/max dots, the chart margins are the same so that dots
+// are not clipped
+const sparklineDotRadius = 1.5
+
+// sparkline removes axes, grid, labels, boxes and margins, and (if SparklineMinMax) adds min/max dots
+func (cp *ChartParams) sparkline() {
+	cp.XAxis = Axis{}
+	cp.YAxis = Axis{}
+	cp.Y2Axis = Axis{}
+	cp.ChartMargin = Padding{}
+	cp.ChartPadding = Padding{}
+	cp.Title = ""
+	cp.Legend = nil
+	cp.statsBox = nil
+
+	markers := make([]Marker, 0, len(cp.Markers))
+	for _, marker := range cp.Markers {
+		// Only dots, vertical markers don't make sense in such small charts
+		if !marker.Vertical {
+			marker.Label = ""
+			markers = append(markers, marker)
+		}
+	}
+	cp.Markers = markers
+	spans := make([]Span, len(cp.Spans))
+	for n, span := range cp.Spans {
+		span.Label = ""
+		spans[n] = span
+	}
+	cp.Spans = spans
+
+	if cp.SparklineMinMax {
+		noLabel := func(float64) string { return "" }
+		cp.Markers = append(cp.Markers, minMaxMarkers(cp.mainPoints(), true, noLabel)...)
+	}
+	if len(cp.Markers) > 0 {
+		margin := math.Ceil(sparklineDotRadius + cp.LineWidth)
+		cp.ChartMargin = Padding{Top: margin, Right: margin, Bottom: margin, Left: margin}
+	}
+}
@@ -0,0 +1,71 @@
+package gpxcharts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestSparkline(t *testing.T) {
+	t.Parallel()
+
+	params := ChartParams{
+		Series:       []Series{{Points: []Point{{0, 1}, {1, 3}, {2, 2}}, Fill: true}},
+		Spans:        []Span{{MinX: 0, MaxX: 1, Label: "climb"}},
+		Markers:      []Marker{{X: 1, Y: 3, Label: "max"}, {X: 2, Vertical: true}},
+		Legend:       []LegendItem{{Label: "legend"}},
+		Title:        "title",
+		XAxis:        Axis{Show: true, Grid: 1, Labels: 1},
+		YAxis:        Axis{Show: true, Grid: 1, Labels: 1},
+		ChartMargin:  Padding{Left: 40, Bottom: 20},
+		ChartPadding: Padding{Top: 10},
+		Sparkline:    true,
+	}
+	prepared := params
+	prepared.sparkline()
+	assert.Equal(t, Axis{}, prepared.XAxis)
+	assert.Equal(t, Axis{}, prepared.YAxis)
+	assert.Equal(t, Padding{}, prepared.ChartPadding)
+	assert.Empty(t, prepared.Legend)
+	assert.Empty(t, prepared.Title)
+	assert.Equal(t, "", prepared.Spans[0].Label)
+	assert.Equal(t, "climb", params.Spans[0].Label)
+	assert.Equal(t, []Marker{{X: 1, Y: 3}}, prepared.Markers)
+	assert.Equal(t, 2., prepared.ChartMargin.Left)
+
+	params.Markers = nil
+	params.SparklineMinMax = true
+	prepared = params
+	prepared.sparkline()
+	assert.Equal(t, 2, len(prepared.Markers))
+	params.SparklineMinMax = false
+	prepared = params
+	prepared.sparkline()
+	assert.Equal(t, Padding{}, prepared.ChartMargin)
+}
+
+func TestSparklineChart(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	params := ChartParams{Sparkline: true, SparklineMinMax: true, Stats: AllStatsFields(), ColorByGrade: true, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}}
+	prepared, err := chartService.elevationChartParams(params, *g)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, prepared.statsBox)
+	assert.NotEmpty(t, prepared.Legend)
+	prepared.sparkline()
+	assert.Empty(t, prepared.statsBox)
+	assert.Empty(t, prepared.Legend)
+	assert.False(t, prepared.XAxis.Show)
+	min, max := minMaxY(prepared.mainPoints())
+	if assert.Equal(t, 2, len(prepared.Markers)) {
+		assert.Equal(t, max, prepared.Markers[0].Y)
+		assert.Equal(t, min, prepared.Markers[1].Y)
+		assert.Empty(t, prepared.Markers[0].Label)
+	}
+	assert.Equal(t, Padding{Top: 2, Right: 2, Bottom: 2, Left: 2}, prepared.ChartMargin)
+}
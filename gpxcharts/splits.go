@@ -0,0 +1,259 @@
+package gpxcharts
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+var (
+	splitColor        = color.RGBA{0x70, 0x90, 0xc0, 0xff}
+	fastestSplitColor = color.RGBA{0x30, 0xa0, 0x30, 0xff}
+	slowestSplitColor = color.RGBA{0xc0, 0x30, 0x30, 0xff}
+)
+
+// SplitMetric is the bar height in SplitsChart
+type SplitMetric string
+
+const (
+	// SplitPace is the pace (in the PaceUnit) of every split (default)
+	SplitPace SplitMetric = "pace"
+	// SplitTime is the time of every split
+	SplitTime SplitMetric = "time"
+)
+
+func AllSplitMetrics() []SplitMetric {
+	return []SplitMetric{
+		SplitPace,
+		SplitTime,
+	}
+}
+
+// Split is a part of the track found by ChartService.Splits, distances are in meters from the start,
+// the duration (elapsed time) is in seconds, and the speed is in m/s
+type Split struct {
+	StartDistance float64 `json:"start_distance"`
+	Distance      float64 `json:"distance"`
+	Duration      float64 `json:"duration"`
+	Speed         float64 `json:"speed"`
+	Ascent        float64 `json:"ascent"`
+	Descent       float64 `json:"descent"`
+}
+
+// Pace is in seconds per meter
+func (s Split) Pace() float64 {
+	if s.Distance <= 0 {
+		return math.NaN()
+	}
+	return s.Duration / s.Distance
+}
+
+// formatMinSec formats seconds as "4:35" (or "1:04:35")
+func formatMinSec(seconds float64) string {
+	if seconds < 0 || IsNanOrOnf(seconds) {
+		return "n/a"
+	}
+	total := int(math.Round(seconds))
+	h, m, s := total/3600, (total%3600)/60, total%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+type splitPoint struct {
+	distance, seconds float64
+}
+
+// valueAt returns the Y value at x, interpolated between points (sorted by X), or the value of the first
+// (last) point before (after) them
+func valueAt(points []Point, x float64) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+	n := sort.Search(len(points), func(i int) bool { return points[i].X >= x })
+	if n == 0 {
+		return points[0].Y
+	}
+	if n == len(points) {
+		return points[n-1].Y
+	}
+	p1, p2 := points[n-1], points[n]
+	if p2.X == p1.X {
+		return p2.Y
+	}
+	return p1.Y + (x-p1.X)/(p2.X-p1.X)*(p2.Y-p1.Y)
+}
+
+// Splits divides the track (points with timestamps) into splits of SplitDistanceOrDefault meters. The
+// last split is shorter if the track length is not a multiple of the split distance. Ascents and descents
+// are from the cumulative ascent/descent (as in Stats), interpolated at split boundaries.
+func (cs ChartService) Splits(params ChartParams, g gpx.GPX) ([]Split, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return nil, err
+	}
+	splitDistance := params.SplitDistanceOrDefault()
+
+	var (
+		points    []splitPoint
+		startTime time.Time
+	)
+	params.XAxisMode = XAxisDistance
+	ascent, descent, _ := cs.cumulativeAscentDescent(params, g)
+	xc := newXAxisCounter(params, g)
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for n, pt := range segment.Points {
+				xc.next(pt, n == 0)
+				if pt.Timestamp.IsZero() {
+					continue
+				}
+				if startTime.IsZero() {
+					startTime = pt.Timestamp
+				}
+				points = append(points, splitPoint{
+					distance: xc.distance,
+					seconds:  pt.Timestamp.Sub(startTime).Seconds(),
+				})
+			}
+		}
+	}
+	if len(points) < 2 {
+		return nil, nil
+	}
+
+	// Distances are from the first point with a timestamp:
+	origin := points[0].distance
+	var (
+		splits       []Split
+		current      Split
+		startSeconds float64
+	)
+	closeSplit := func(distance, seconds float64) {
+		current.Distance = distance - current.StartDistance
+		current.Duration = seconds - startSeconds
+		current.Ascent = valueAt(ascent.points, origin+distance) - valueAt(ascent.points, origin+current.StartDistance)
+		current.Descent = valueAt(descent.points, origin+distance) - valueAt(descent.points, origin+current.StartDistance)
+		if current.Duration > 0 {
+			current.Speed = current.Distance / current.Duration
+		}
+		splits = append(splits, current)
+		current = Split{StartDistance: distance}
+		startSeconds = seconds
+	}
+	for n := 1; n < len(points); n++ {
+		p1, p2 := points[n-1], points[n]
+		d1, d2 := p1.distance-origin, p2.distance-origin
+		for boundary := current.StartDistance + splitDistance; d2 >= boundary; boundary += splitDistance {
+			f := (boundary - d1) / (d2 - d1)
+			closeSplit(boundary, p1.seconds+f*(p2.seconds-p1.seconds))
+		}
+	}
+	// The last (shorter) split, if it is not just a rounding error:
+	if last := points[len(points)-1]; last.distance-origin > current.StartDistance+1 {
+		closeSplit(last.distance-origin, last.seconds)
+	}
+	return splits, nil
+}
+
+// fastestAndSlowest returns the indexes of the fastest and slowest splits, splits shorter than half of
+// the split distance are ignored
+func fastestAndSlowest(splits []Split, splitDistance float64) (int, int) {
+	fastest, slowest := -1, -1
+	for n, split := range splits {
+		if split.Distance < splitDistance/2 || split.Duration <= 0 {
+			continue
+		}
+		if fastest < 0 || split.Pace() < splits[fastest].Pace() {
+			fastest = n
+		}
+		if slowest < 0 || split.Pace() > splits[slowest].Pace() {
+			slowest = n
+		}
+	}
+	return fastest, slowest
+}
+
+func (cs ChartService) prepareSplitsAxis(axis *Axis, splits []Split, unitType UnitType) {
+	axis.Formatter = func(f float64) string {
+		n := int(math.Round(f)) - 1
+		if 0 <= n && n < len(splits) {
+			return FormatLength(splits[n].StartDistance+splits[n].Distance, unitType)
+		}
+		return ""
+	}
+	if axis.Labels == 0 {
+		axis.Labels = math.Max(1, math.Ceil(float64(len(splits))/15))
+	}
+}
+
+// SplitsChart shows a bar with the pace (or time, by SplitMetric) of every split, with the fastest
+// and the slowest splits colored
+func (cs ChartService) SplitsChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.splitsChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+func (cs ChartService) splitsChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	splits, err := cs.Splits(params, g)
+	if err != nil {
+		return params, err
+	}
+
+	unitType := params.UnitTypeOrMetric()
+	paceUnit := params.PaceUnitOrDefault()
+	metric := params.SplitMetricOrDefault()
+	fastest, slowest := fastestAndSlowest(splits, params.SplitDistanceOrDefault())
+
+	var max float64
+	params.Series = nil
+	params.Bars = nil
+	for n, split := range splits {
+		v := split.Duration
+		if metric == SplitPace {
+			v = split.Pace() * paceUnit.Length()
+		}
+		if IsNanOrOnf(v) {
+			continue
+		}
+		max = math.Max(max, v)
+		barColor := splitColor
+		switch n {
+		case fastest:
+			barColor = fastestSplitColor
+		case slowest:
+			barColor = slowestSplitColor
+		}
+		params.Bars = append(params.Bars, Bar{
+			MinX:  float64(n) + 0.6,
+			MaxX:  float64(n) + 1.4,
+			MinY:  0,
+			MaxY:  v,
+			Color: barColor,
+			Label: formatMinSec(v),
+		})
+	}
+
+	// Chart padding is in chart units, which doesn't make sense for splits:
+	params.ChartPadding = Padding{}
+	params.MinX, params.MaxX = 0.5, float64(len(splits))+0.5
+	// Space for labels above bars:
+	params.MinY, params.MaxY = 0, 1.15*math.Max(max, 1)
+	cs.prepareSplitsAxis(&params.XAxis, splits, unitType)
+	if metric == SplitPace {
+		cs.preparePaceAxis(&params.YAxis, 0, max, paceUnit)
+		params.YAxis.Inverted = false
+	} else {
+		cs.prepareDurationAxis(&params.YAxis, max)
+	}
+	return params, nil
+}
@@ -0,0 +1,135 @@
+package gpxcharts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// pacedGPX returns a flat track with the given paces (in seconds per km) for every km
+func pacedGPX(paces ...float64) gpx.GPX {
+	g := profileGPX(make([]float64, len(paces))...)
+	points := g.Tracks[0].Segments[0].Points
+	t := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+	for n := range points {
+		if n > 0 {
+			t = t.Add(time.Duration(paces[(n-1)/100] / 100 * float64(time.Second)))
+		}
+		points[n].Timestamp = t
+	}
+	return g
+}
+
+func TestSplits(t *testing.T) {
+	t.Parallel()
+
+	g := pacedGPX(300, 240, 360)
+	splits, err := chartService.Splits(ChartParams{}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(splits)) {
+		for n, duration := range []float64{300, 240, 360} {
+			assert.InDelta(t, 1000*float64(n), splits[n].StartDistance, 0.01)
+			assert.InDelta(t, 1000, splits[n].Distance, 0.01)
+			assert.InDelta(t, duration, splits[n].Duration, 0.01)
+			assert.InDelta(t, 1000/duration, splits[n].Speed, 0.001)
+		}
+	}
+	fastest, slowest := fastestAndSlowest(splits, 1000)
+	assert.Equal(t, 1, fastest)
+	assert.Equal(t, 2, slowest)
+
+	splits, err = chartService.Splits(ChartParams{SplitDistance: 1200}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(splits)) {
+		assert.InDelta(t, 348, splits[0].Duration, 0.01)
+		assert.InDelta(t, 336, splits[1].Duration, 0.01)
+		assert.InDelta(t, 600, splits[2].Distance, 0.01)
+		assert.InDelta(t, 216, splits[2].Duration, 0.01)
+	}
+
+	splits, err = chartService.Splits(ChartParams{Unit: UnitTypeImperial}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 2, len(splits)) {
+		assert.InDelta(t, ONE_MILE, splits[0].Distance, 0.01)
+		assert.InDelta(t, 3000-ONE_MILE, splits[1].Distance, 0.01)
+	}
+
+	// The pace unit is only used for paces:
+	splits, err = chartService.Splits(ChartParams{PaceUnit: PaceUnit100m}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(splits)) {
+		assert.InDelta(t, 1000, splits[0].Distance, 0.01)
+		assert.InDelta(t, 300, splits[0].Duration, 0.01)
+	}
+	assert.Equal(t, 1000., ChartParams{PaceUnit: PaceUnit100m}.SplitDistanceOrDefault())
+	assert.Equal(t, ONE_MILE, ChartParams{Unit: UnitTypeImperial, PaceUnit: PaceUnitKm}.SplitDistanceOrDefault())
+
+	splits, err = chartService.Splits(ChartParams{}, profileGPX(1, 2))
+	assert.Nil(t, err)
+	assert.Empty(t, splits)
+
+	assert.Equal(t, "4:35", formatMinSec(275))
+	assert.Equal(t, "1:04:35", formatMinSec(3875))
+}
+
+func TestSplitsAscentDescent(t *testing.T) {
+	t.Parallel()
+
+	for _, fn := range []string{"../test_files/garmin.gpx", "../test_files/parenzana.gpx"} {
+		g, err := gpx.ParseFile(fn)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		for _, splitDistance := range []float64{200, 1000} {
+			params := ChartParams{SplitDistance: splitDistance}
+			splits, err := chartService.Splits(params, *g)
+			assert.Nil(t, err)
+			assert.NotEmpty(t, splits)
+			var ascent, descent float64
+			for _, split := range splits {
+				assert.True(t, split.Ascent >= 0 && split.Descent >= 0, fn)
+				ascent += split.Ascent
+				descent += split.Descent
+			}
+			stats := chartService.Stats(params, *g)
+			assert.InDelta(t, stats.Ascent, ascent, 0.01, fn)
+			assert.InDelta(t, stats.Descent, descent, 0.01, fn)
+		}
+	}
+
+	assert.Equal(t, 0., valueAt(nil, 1))
+	points := []Point{{0, 0}, {10, 5}, {10, 6}, {20, 10}}
+	assert.Equal(t, 0., valueAt(points, -1))
+	assert.Equal(t, 2.5, valueAt(points, 5))
+	assert.Equal(t, 8., valueAt(points, 15))
+	assert.Equal(t, 10., valueAt(points, 25))
+}
+
+func TestSplitsChart(t *testing.T) {
+	t.Parallel()
+
+	g := pacedGPX(300, 240, 360, 300)
+	for _, metric := range AllSplitMetrics() {
+		params, err := chartService.splitsChartParams(ChartParams{SplitMetric: metric, PaceUnit: PaceUnitMile}, g)
+		assert.Nil(t, err)
+		if !assert.Equal(t, 4, len(params.Bars)) {
+			continue
+		}
+		for n, bar := range params.Bars {
+			assert.InDelta(t, float64(n+1), (bar.MinX+bar.MaxX)/2, 0.001)
+		}
+		assert.Equal(t, splitColor, params.Bars[0].Color)
+		assert.Equal(t, fastestSplitColor, params.Bars[1].Color)
+		assert.Equal(t, slowestSplitColor, params.Bars[2].Color)
+		switch metric {
+		case SplitPace:
+			assert.InDelta(t, 240*ONE_MILE/1000, params.Bars[1].MaxY, 0.01)
+		default:
+			assert.InDelta(t, 240, params.Bars[1].MaxY, 0.01)
+			assert.Equal(t, "4:00", params.Bars[1].Label)
+		}
+		assert.Equal(t, "4km", params.XAxis.Formatter(4))
+	}
+}
@@ -0,0 +1,129 @@
+package gpxcharts
+
+import (
+	"image/color"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+var (
+	maxMarkerColor = color.RGBA{0xc0, 0x20, 0x20, 0xff}
+	minMarkerColor = color.RGBA{0x20, 0x40, 0xc0, 0xff}
+)
+
+type StatsField string
+
+const (
+	StatsDistance     StatsField = "distance"
+	StatsAscent       StatsField = "ascent"
+	StatsDescent      StatsField = "descent"
+	StatsMovingTime   StatsField = "moving"
+	StatsAverageSpeed StatsField = "speed"
+)
+
+func AllStatsFields() []StatsField {
+	return []StatsField{
+		StatsDistance,
+		StatsAscent,
+		StatsDescent,
+		StatsMovingTime,
+		StatsAverageSpeed,
+	}
+}
+
+// Name is used in the stats box
+func (sf StatsField) Name() string {
+	switch sf {
+	case StatsDistance:
+		return "Distance"
+	case StatsAscent:
+		return "Ascent"
+	case StatsDescent:
+		return "Descent"
+	case StatsMovingTime:
+		return "Moving time"
+	case StatsAverageSpeed:
+		return "Avg. speed"
+	default:
+		return string(sf)
+	}
+}
+
+// Stats are the totals shown in the stats box. Distance (in meters) is without gaps between segments
+// (unless ChartParams.GapDistance), moving time is in seconds, and the average speed (in m/s) is the
+// distance divided by the moving time.
+type Stats struct {
+	Distance     float64
+	Ascent       float64
+	Descent      float64
+	MovingTime   float64
+	AverageSpeed float64
+}
+
+func (cs ChartService) Stats(params ChartParams, g gpx.GPX) Stats {
+	var stats Stats
+	ascent, descent, xc := cs.cumulativeAscentDescent(params, g)
+	if n := len(ascent.points); n > 0 {
+		stats.Ascent, stats.Descent = ascent.points[n-1].Y, descent.points[n-1].Y
+	}
+	stats.Distance, stats.MovingTime = xc.distance, xc.moving
+	if stats.MovingTime > 0 {
+		stats.AverageSpeed = stats.Distance / stats.MovingTime
+	}
+	return stats
+}
+
+// Format returns the formatted value of the field
+func (s Stats) Format(field StatsField, unitType UnitType) string {
+	switch field {
+	case StatsDistance:
+		return FormatLength(s.Distance, unitType)
+	case StatsAscent:
+		return FormatAltitude(s.Ascent, unitType)
+	case StatsDescent:
+		return FormatAltitude(s.Descent, unitType)
+	case StatsMovingTime:
+		return FormatDuration(s.MovingTime)
+	case StatsAverageSpeed:
+		return FormatSpeed(s.AverageSpeed, unitType, false)
+	default:
+		return "n/a"
+	}
+}
+
+// statsBox returns the stats box lines for ChartParams.Stats
+func (cs ChartService) statsBox(params ChartParams, g gpx.GPX) []LegendItem {
+	if len(params.Stats) == 0 {
+		return nil
+	}
+	stats := cs.Stats(params, g)
+	var res []LegendItem
+	for _, field := range params.Stats {
+		res = append(res, LegendItem{Label: field.Name() + ": " + stats.Format(field, params.UnitTypeOrMetric())})
+	}
+	return res
+}
+
+// minMaxMarkers returns the markers (with formatted values) for the maximum and (optionally) minimum point
+func minMaxMarkers(points []Point, withMin bool, formatter func(float64) string) []Marker {
+	if len(points) == 0 {
+		return nil
+	}
+	min, max := points[0], points[0]
+	for _, pt := range points {
+		if IsNanOrOnf(pt.Y) {
+			continue
+		}
+		if pt.Y < min.Y || IsNanOrOnf(min.Y) {
+			min = pt
+		}
+		if pt.Y > max.Y || IsNanOrOnf(max.Y) {
+			max = pt
+		}
+	}
+	res := []Marker{{X: max.X, Y: max.Y, Color: maxMarkerColor, Label: formatter(max.Y)}}
+	if withMin {
+		res = append(res, Marker{X: min.X, Y: min.Y, Color: minMarkerColor, Label: formatter(min.Y)})
+	}
+	return res
+}
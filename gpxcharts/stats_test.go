@@ -0,0 +1,78 @@
+package gpxcharts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	updo := g.UphillDownhill()
+
+	stats := chartService.Stats(ChartParams{AscentThreshold: -1}, *g)
+	assert.InDelta(t, g.Length2D(), stats.Distance, 1)
+	assert.InDelta(t, updo.Uphill, stats.Ascent, 0.001)
+	assert.InDelta(t, updo.Downhill, stats.Descent, 0.001)
+	assert.True(t, stats.MovingTime > 0)
+	assert.True(t, stats.MovingTime <= g.Duration())
+	assert.InDelta(t, stats.Distance/stats.MovingTime, stats.AverageSpeed, 0.001)
+
+	stats = Stats{Distance: 12345, Ascent: 300, Descent: 200, MovingTime: 3600 + 30*60, AverageSpeed: 5}
+	assert.Equal(t, "12.35km", stats.Format(StatsDistance, UnitTypeMetric))
+	assert.Equal(t, "7.67mi", stats.Format(StatsDistance, UnitTypeImperial))
+	assert.Equal(t, "300m", stats.Format(StatsAscent, UnitTypeMetric))
+	assert.Equal(t, "656ft", stats.Format(StatsDescent, UnitTypeImperial))
+	assert.Equal(t, "1h30", stats.Format(StatsMovingTime, UnitTypeMetric))
+	assert.Equal(t, "18.0kmh", stats.Format(StatsAverageSpeed, UnitTypeMetric))
+
+	box := chartService.statsBox(ChartParams{Stats: []StatsField{StatsAscent, StatsDistance}, AscentThreshold: -1}, *g)
+	if assert.Equal(t, 2, len(box)) {
+		assert.Equal(t, "Ascent: "+FormatAltitude(updo.Uphill, UnitTypeMetric), box[0].Label)
+		assert.True(t, strings.HasPrefix(box[1].Label, "Distance: "))
+	}
+	assert.Empty(t, chartService.statsBox(ChartParams{}, *g))
+}
+
+func TestMinMaxMarkers(t *testing.T) {
+	t.Parallel()
+
+	points := []Point{{0, 5}, {1, 3}, {2, 8}, {3, 4}}
+	markers := minMaxMarkers(points, true, func(f float64) string { return FormatFloat(f, 0) })
+	if assert.Equal(t, 2, len(markers)) {
+		assert.Equal(t, Marker{X: 2, Y: 8, Color: maxMarkerColor, Label: "8"}, markers[0])
+		assert.Equal(t, Marker{X: 1, Y: 3, Color: minMarkerColor, Label: "3"}, markers[1])
+	}
+	assert.Equal(t, 1, len(minMaxMarkers(points, false, FormatDuration)))
+	assert.Empty(t, minMaxMarkers(nil, true, FormatDuration))
+}
+
+func TestChartsWithStats(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	params := ChartParams{Width: 900, Height: 250, Stats: AllStatsFields(), MinMaxMarkers: true, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}}
+
+	prepared, err := chartService.elevationChartParams(params, *g)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(prepared.Markers))
+	assert.Equal(t, len(AllStatsFields()), len(prepared.statsBox))
+	stats := chartService.Stats(params, *g)
+	for n, field := range AllStatsFields() {
+		assert.Equal(t, field.Name()+": "+stats.Format(field, UnitTypeMetric), prepared.statsBox[n].Label)
+	}
+	prepared, err = chartService.speedChartParams(params, *g)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(prepared.Markers))
+	assert.Equal(t, len(AllStatsFields()), len(prepared.statsBox))
+}
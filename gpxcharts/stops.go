@@ -0,0 +1,107 @@
+package gpxcharts
+
+import (
+	"image/color"
+	"time"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// DefaultStopDuration is the minimum duration of a stop (shorter stops are ignored)
+const DefaultStopDuration = 20 * time.Second
+
+// stopSpeedWindow is the time window of speeds used to find stops. Speeds are the distance between the
+// first and the last point in the window, so that GPS jitter while stopped doesn't split stops.
+const stopSpeedWindow = 20 * time.Second
+
+var stopColor = color.RGBA{0x30, 0x30, 0x50, 0x50}
+
+// stop is a period when the speed was below the stop speed threshold
+type stop struct {
+	start, end time.Time
+	// startX and endX are set by the xAxisCounter when it reaches the first and the last stop point
+	startX, endX     float64
+	hasStart, hasEnd bool
+}
+
+func (s stop) seconds() float64 {
+	return s.end.Sub(s.start).Seconds()
+}
+
+// findStops returns the stops (with speeds, smoothed over stopSpeedWindow, below StopSpeedOrDefault for
+// at least StopDurationOrDefault) in chronological order. Stops don't span more segments.
+func findStops(params ChartParams, g gpx.GPX) []stop {
+	speedThreshold := params.StopSpeedOrDefault()
+	minDuration := params.StopDurationOrDefault().Seconds()
+	halfWindow := stopSpeedWindow / 2
+	var stops []stop
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			var current *stop
+			closeStop := func() {
+				if current != nil && current.seconds() >= minDuration {
+					stops = append(stops, *current)
+				}
+				current = nil
+			}
+			for n := 1; n < len(segment.Points); n++ {
+				prev, pt := segment.Points[n-1], segment.Points[n]
+				if prev.Timestamp.IsZero() || pt.Timestamp.IsZero() {
+					closeStop()
+					continue
+				}
+				if !pt.Timestamp.After(prev.Timestamp) {
+					continue
+				}
+				from, to := n-1, n
+				for from > 0 && !segment.Points[from-1].Timestamp.IsZero() && prev.Timestamp.Sub(segment.Points[from-1].Timestamp) < halfWindow {
+					from--
+				}
+				for to+1 < len(segment.Points) && !segment.Points[to+1].Timestamp.IsZero() && segment.Points[to+1].Timestamp.Sub(pt.Timestamp) < halfWindow {
+					to++
+				}
+				fromPt, toPt := segment.Points[from], segment.Points[to]
+				seconds := toPt.Timestamp.Sub(fromPt.Timestamp).Seconds()
+				if seconds <= 0 {
+					continue
+				}
+				if toPt.Distance2D(&fromPt)/seconds >= speedThreshold {
+					closeStop()
+					continue
+				}
+				if current == nil {
+					current = &stop{start: prev.Timestamp}
+				}
+				current.end = pt.Timestamp
+			}
+			closeStop()
+		}
+	}
+	return stops
+}
+
+// stoppedBefore returns the stopped time (in seconds) before t
+func stoppedBefore(stops []stop, t time.Time) float64 {
+	var res float64
+	for _, s := range stops {
+		if !s.start.Before(t) {
+			break
+		}
+		if s.end.Before(t) {
+			res += s.seconds()
+		} else {
+			res += t.Sub(s.start).Seconds()
+		}
+	}
+	return res
+}
+
+func stopSpans(stops []stop) []Span {
+	var spans []Span
+	for _, s := range stops {
+		if s.hasStart && s.hasEnd && s.startX < s.endX {
+			spans = append(spans, Span{MinX: s.startX, MaxX: s.endX, Color: stopColor})
+		}
+	}
+	return spans
+}
@@ -0,0 +1,113 @@
+package gpxcharts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// stopsGPX returns a track with points every 10s (moving 50m) with a 60s and a 10s stop
+func stopsGPX() gpx.GPX {
+	start := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+	var (
+		segment gpx.GPXTrackSegment
+		lon     float64
+	)
+	for n := 0; n <= 30; n++ {
+		stopped := (10 < n && n <= 16) || n == 25
+		if n > 0 && !stopped {
+			lon += 50 / oneDegree
+		}
+		segment.Points = append(segment.Points, gpx.GPXPoint{Point: gpx.Point{Latitude: 0, Longitude: lon}, Timestamp: start.Add(time.Duration(n) * 10 * time.Second)})
+	}
+	return gpx.GPX{Tracks: []gpx.GPXTrack{{Segments: []gpx.GPXTrackSegment{segment}}}}
+}
+
+func TestFindStops(t *testing.T) {
+	t.Parallel()
+
+	g := stopsGPX()
+	stops := findStops(ChartParams{}, g)
+	if assert.Equal(t, 1, len(stops)) {
+		assert.Equal(t, 60., stops[0].seconds())
+		assert.Equal(t, g.Tracks[0].Segments[0].Points[10].Timestamp, stops[0].start)
+	}
+	assert.Equal(t, 2, len(findStops(ChartParams{StopDuration: 10 * time.Second}, g)))
+	assert.Equal(t, 1, len(findStops(ChartParams{StopSpeed: 1}, g)))
+	if all := findStops(ChartParams{StopSpeed: 10}, g); assert.Equal(t, 1, len(all)) {
+		assert.Equal(t, 300., all[0].seconds())
+	}
+	assert.Empty(t, findStops(ChartParams{StopSpeed: 10, StopDuration: time.Hour}, g))
+
+	assert.Equal(t, 0., stoppedBefore(stops, stops[0].start))
+	assert.Equal(t, 20., stoppedBefore(stops, stops[0].start.Add(20*time.Second)))
+	assert.Equal(t, 60., stoppedBefore(stops, stops[0].end.Add(time.Hour)))
+}
+
+func TestFindStopsWithJitter(t *testing.T) {
+	t.Parallel()
+
+	// Points every second, moving 5m/s for a minute, stopped (with GPS jitter) for a minute, and moving again:
+	start := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+	var (
+		segment gpx.GPXTrackSegment
+		lon     float64
+	)
+	for n := 0; n <= 180; n++ {
+		jitter := 0.
+		if 60 < n && n <= 120 {
+			jitter = float64(n%2*2-1) / oneDegree
+		} else if n > 0 {
+			lon += 5 / oneDegree
+		}
+		segment.Points = append(segment.Points, gpx.GPXPoint{Point: gpx.Point{Latitude: jitter, Longitude: lon}, Timestamp: start.Add(time.Duration(n) * time.Second)})
+	}
+	g := gpx.GPX{Tracks: []gpx.GPXTrack{{Segments: []gpx.GPXTrackSegment{segment}}}}
+
+	stops := findStops(ChartParams{}, g)
+	if assert.Equal(t, 1, len(stops)) {
+		assert.InDelta(t, 60, stops[0].seconds(), stopSpeedWindow.Seconds())
+		assert.InDelta(t, 60, stops[0].start.Sub(start).Seconds(), stopSpeedWindow.Seconds()/2)
+	}
+}
+
+func TestStopsOnCharts(t *testing.T) {
+	t.Parallel()
+
+	g := stopsGPX()
+	prepared, err := chartService.speedChartParams(ChartParams{XAxisMode: XAxisElapsedTime, Stops: true}, g)
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(prepared.Spans)) {
+		assert.Equal(t, Span{MinX: 100, MaxX: 160, Color: stopColor}, prepared.Spans[0])
+	}
+	prepared, err = chartService.elevationChartParams(ChartParams{XAxisMode: XAxisElapsedTime, Stops: true, CollapseStops: true}, g)
+	assert.Nil(t, err)
+	assert.Empty(t, prepared.Spans)
+	assert.Equal(t, 240., prepared.mainPoints()[len(prepared.mainPoints())-1].X)
+
+	// Stops are collapsed only on the elapsed time axis:
+	for _, mode := range []XAxisMode{XAxisDistance, XAxisMovingTime, XAxisClockTime} {
+		expected, err := chartService.speedChartParams(ChartParams{XAxisMode: mode}, g)
+		assert.Nil(t, err)
+		prepared, err := chartService.speedChartParams(ChartParams{XAxisMode: mode, CollapseStops: true}, g)
+		assert.Nil(t, err)
+		assert.Equal(t, expected.mainPoints(), prepared.mainPoints(), mode)
+	}
+
+	garmin, err := gpx.ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	params := ChartParams{Width: 900, Height: 200, XAxisMode: XAxisElapsedTime, Stops: true, StopSpeed: 1, StopDuration: 10 * time.Second, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}}
+	prepared, err = chartService.speedChartParams(params, *garmin)
+	assert.Nil(t, err)
+	stops := findStops(params, *garmin)
+	assert.NotEmpty(t, stops)
+	assert.Equal(t, len(stops), len(prepared.Spans))
+	for _, span := range prepared.Spans {
+		assert.Equal(t, stopColor, span.Color)
+		assert.True(t, span.MaxX > span.MinX)
+	}
+}
@@ -0,0 +1,196 @@
+package gpxcharts
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+)
+
+// Theme defines the colors of all chart elements (except colors set in series, spans, bars and markers),
+// zero colors are taken from LightTheme
+type Theme struct {
+	Background color.RGBA
+	Grid       color.RGBA
+	// Separator lines (between tracks)
+	Separator color.RGBA
+	// Axis lines and labels
+	Axis color.RGBA
+	// Label is the color of titles, bar and span labels and text in boxes
+	Label         color.RGBA
+	BoxBorder     color.RGBA
+	BoxBackground color.RGBA
+	// Stroke is the default color of series lines
+	Stroke color.RGBA
+	// Fill is the default fill color of series, NegativeFill is used for values below zero
+	Fill         color.RGBA
+	NegativeFill color.RGBA
+	// MarkerBorder is the border around marker dots
+	MarkerBorder color.RGBA
+	// Invalid is the color of the message shown when there is not enough data
+	Invalid color.RGBA
+	// TransparentBackground is set by ParseTheme for backgrounds with zero alpha
+	TransparentBackground bool
+}
+
+// colors returns pointers to all theme colors
+func (t *Theme) colors() []*color.RGBA {
+	return []*color.RGBA{
+		&t.Background,
+		&t.Grid,
+		&t.Separator,
+		&t.Axis,
+		&t.Label,
+		&t.BoxBorder,
+		&t.BoxBackground,
+		&t.Stroke,
+		&t.Fill,
+		&t.NegativeFill,
+		&t.MarkerBorder,
+		&t.Invalid,
+	}
+}
+
+// withDefaults returns the theme with zero colors taken from base
+func (t Theme) withDefaults(base Theme) Theme {
+	baseColors := base.colors()
+	for n, c := range t.colors() {
+		if *c == (color.RGBA{}) {
+			*c = *baseColors[n]
+		}
+	}
+	return t
+}
+
+// ThemeName is the name of a built in theme
+type ThemeName string
+
+const (
+	ThemeLight ThemeName = "light"
+	ThemeDark  ThemeName = "dark"
+	ThemePrint ThemeName = "print"
+)
+
+func AllThemeNames() []ThemeName {
+	return []ThemeName{
+		ThemeLight,
+		ThemeDark,
+		ThemePrint,
+	}
+}
+
+// LightTheme is the default theme
+var LightTheme = Theme{
+	Background:    color.RGBA{0xff, 0xff, 0xff, 0xff},
+	Grid:          color.RGBA{0xe0, 0xe0, 0xe0, 0xff},
+	Separator:     color.RGBA{0xa0, 0xa0, 0xa0, 0xff},
+	Axis:          color.RGBA{0x36, 0x6a, 0xff, 0xff},
+	Label:         color.RGBA{0x30, 0x30, 0x30, 0xff},
+	BoxBorder:     color.RGBA{0xa0, 0xa0, 0xa0, 0xff},
+	BoxBackground: color.RGBA{0xe0, 0xe0, 0xe0, 0xe0},
+	Stroke:        color.RGBA{0x00, 0x00, 0x00, 0xaf},
+	Fill:          defaultFillColor,
+	NegativeFill:  defaultFillColor,
+	MarkerBorder:  color.RGBA{0xff, 0xff, 0xff, 0xff},
+	Invalid:       color.RGBA{0xff, 0x4e, 0x00, 0xff},
+}
+
+// DarkTheme has light lines and labels on a dark background
+var DarkTheme = Theme{
+	Background:    color.RGBA{0x1e, 0x1e, 0x22, 0xff},
+	Grid:          color.RGBA{0x3a, 0x3a, 0x40, 0xff},
+	Separator:     color.RGBA{0x70, 0x70, 0x78, 0xff},
+	Axis:          color.RGBA{0x6f, 0x9c, 0xff, 0xff},
+	Label:         color.RGBA{0xd8, 0xd8, 0xd8, 0xff},
+	BoxBorder:     color.RGBA{0x70, 0x70, 0x78, 0xff},
+	BoxBackground: color.RGBA{0x2a, 0x2a, 0x30, 0xe0},
+	Stroke:        color.RGBA{0xd0, 0xd0, 0xd0, 0xd0},
+	Fill:          color.RGBA{0x50, 0x50, 0x50, 0x50},
+	NegativeFill:  color.RGBA{0x50, 0x50, 0x50, 0x50},
+	MarkerBorder:  color.RGBA{0x1e, 0x1e, 0x22, 0xff},
+	Invalid:       color.RGBA{0xff, 0x7a, 0x30, 0xff},
+}
+
+// PrintTheme is black on white, without transparency
+var PrintTheme = Theme{
+	Background:    color.RGBA{0xff, 0xff, 0xff, 0xff},
+	Grid:          color.RGBA{0xc8, 0xc8, 0xc8, 0xff},
+	Separator:     color.RGBA{0x80, 0x80, 0x80, 0xff},
+	Axis:          color.RGBA{0x00, 0x00, 0x00, 0xff},
+	Label:         color.RGBA{0x00, 0x00, 0x00, 0xff},
+	BoxBorder:     color.RGBA{0x00, 0x00, 0x00, 0xff},
+	BoxBackground: color.RGBA{0xff, 0xff, 0xff, 0xff},
+	Stroke:        color.RGBA{0x00, 0x00, 0x00, 0xff},
+	Fill:          color.RGBA{0xc8, 0xc8, 0xc8, 0xff},
+	NegativeFill:  color.RGBA{0x90, 0x90, 0x90, 0xff},
+	MarkerBorder:  color.RGBA{0xff, 0xff, 0xff, 0xff},
+	Invalid:       color.RGBA{0x00, 0x00, 0x00, 0xff},
+}
+
+// BuiltinTheme returns the theme by name
+func BuiltinTheme(name ThemeName) (Theme, error) {
+	switch name {
+	case ThemeLight:
+		return LightTheme, nil
+	case ThemeDark:
+		return DarkTheme, nil
+	case ThemePrint:
+		return PrintTheme, nil
+	}
+	return Theme{}, fmt.Errorf("invalid theme: %s", name)
+}
+
+// ParseTheme parses a JSON theme with hex colors ("rrggbb" or "rrggbbaa"), for example
+// {"base": "dark", "axis": "ffa040"}. Colors not in the JSON are from the base theme (default light).
+func ParseTheme(byts []byte) (Theme, error) {
+	var fields struct {
+		Base          ThemeName `json:"base"`
+		Background    string    `json:"background"`
+		Grid          string    `json:"grid"`
+		Separator     string    `json:"separator"`
+		Axis          string    `json:"axis"`
+		Label         string    `json:"label"`
+		BoxBorder     string    `json:"box_border"`
+		BoxBackground string    `json:"box_background"`
+		Stroke        string    `json:"stroke"`
+		Fill          string    `json:"fill"`
+		NegativeFill  string    `json:"negative_fill"`
+		MarkerBorder  string    `json:"marker_border"`
+		Invalid       string    `json:"invalid"`
+	}
+	if err := json.Unmarshal(byts, &fields); err != nil {
+		return Theme{}, fmt.Errorf("invalid theme: %w", err)
+	}
+	if fields.Base == "" {
+		fields.Base = ThemeLight
+	}
+	theme, err := BuiltinTheme(fields.Base)
+	if err != nil {
+		return Theme{}, err
+	}
+	for _, field := range []struct {
+		hex string
+		c   *color.RGBA
+	}{
+		{fields.Background, &theme.Background},
+		{fields.Grid, &theme.Grid},
+		{fields.Separator, &theme.Separator},
+		{fields.Axis, &theme.Axis},
+		{fields.Label, &theme.Label},
+		{fields.BoxBorder, &theme.BoxBorder},
+		{fields.BoxBackground, &theme.BoxBackground},
+		{fields.Stroke, &theme.Stroke},
+		{fields.Fill, &theme.Fill},
+		{fields.NegativeFill, &theme.NegativeFill},
+		{fields.MarkerBorder, &theme.MarkerBorder},
+		{fields.Invalid, &theme.Invalid},
+	} {
+		if field.hex == "" {
+			continue
+		}
+		if *field.c, err = ParseHexColor(field.hex); err != nil {
+			return Theme{}, err
+		}
+	}
+	theme.TransparentBackground = theme.Background.A == 0
+	return theme, nil
+}
@@ -0,0 +1,92 @@
+package gpxcharts
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestParseTheme(t *testing.T) {
+	t.Parallel()
+
+	theme, err := ParseTheme([]byte(`{"base": "dark", "axis": "ffa040", "fill": "#ffffff40"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, color.RGBA{0xff, 0xa0, 0x40, 0xff}, theme.Axis)
+	assert.Equal(t, color.RGBA{0x40, 0x40, 0x40, 0x40}, theme.Fill)
+	assert.Equal(t, DarkTheme.Background, theme.Background)
+
+	theme, err = ParseTheme([]byte(`{}`))
+	assert.Nil(t, err)
+	assert.Equal(t, LightTheme, theme)
+
+	_, err = ParseTheme([]byte(`{"base": "unknown"}`))
+	assert.NotNil(t, err)
+	_, err = ParseTheme([]byte(`{"grid": "xyz"}`))
+	assert.NotNil(t, err)
+
+	for _, name := range AllThemeNames() {
+		_, err := BuiltinTheme(name)
+		assert.Nil(t, err, name)
+	}
+}
+
+func TestThemeOrDefault(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, LightTheme, ChartParams{}.ThemeOrDefault())
+	assert.Equal(t, DarkTheme, ChartParams{Theme: DarkTheme}.ThemeOrDefault())
+
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	expected := LightTheme
+	expected.Stroke = red
+	assert.Equal(t, expected, ChartParams{Theme: Theme{Stroke: red}}.ThemeOrDefault())
+
+	theme, err := ParseTheme([]byte(`{"base": "dark", "background": "00000000"}`))
+	assert.Nil(t, err)
+	assert.True(t, theme.TransparentBackground)
+	assert.Equal(t, LightTheme.Background, ChartParams{Theme: theme}.ThemeOrDefault().Background)
+	assert.Equal(t, DarkTheme.Axis, ChartParams{Theme: theme}.ThemeOrDefault().Axis)
+}
+
+func TestThemeFillColors(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, LightTheme.Fill, ChartParams{}.fillColor(positive))
+	assert.Equal(t, PrintTheme.NegativeFill, ChartParams{Theme: PrintTheme}.fillColor(negative))
+	red := color.RGBA{0xff, 0, 0, 0xff}
+	assert.Equal(t, red, ChartParams{Theme: PrintTheme, FillColor: red}.fillColor(negative))
+}
+
+func TestThemeCharts(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	for _, name := range AllThemeNames() {
+		theme, _ := BuiltinTheme(name)
+		params := ChartParams{Width: 900, Height: 250, Theme: theme, MaxY: 2000}
+		byts, err := chartService.ElevationChart(context.Background(), params, *g, OutputPNG)
+		if !assert.Nil(t, err) {
+			continue
+		}
+		img, err := png.Decode(bytes.NewReader(byts))
+		if !assert.Nil(t, err) {
+			continue
+		}
+		// the elevation is below the middle of the chart, the top is background and the bottom is filled
+		assert.Equal(t, theme.Background, color.RGBAModel.Convert(img.At(450, 5)), name)
+		filled := image.NewRGBA(image.Rect(0, 0, 1, 1))
+		filled.Set(0, 0, theme.Background)
+		draw.Draw(filled, filled.Bounds(), image.NewUniform(theme.Fill), image.Point{}, draw.Over)
+		assert.Equal(t, filled.At(0, 0), color.RGBAModel.Convert(img.At(450, 245)), name)
+	}
+}
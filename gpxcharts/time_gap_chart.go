@@ -0,0 +1,186 @@
+package gpxcharts
+
+import (
+	"context"
+	"errors"
+	"image/color"
+	"math"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+const (
+	// Reference points farther than this from the attempt (in meters) are not matched
+	maxTimeGapMatchDistance = 50.
+	// How far ahead (in meters) from the last matched position the next one is searched
+	timeGapSearchDistance = 1000.
+)
+
+var (
+	timeGapBehindColor = color.RGBA{0x60, 0x08, 0x08, 0x80}
+	timeGapZeroColor   = color.RGBA{0x80, 0x80, 0x80, 0xff}
+)
+
+// pointsWithTime returns all track points with timestamps
+func pointsWithTime(g gpx.GPX) []gpx.GPXPoint {
+	var res []gpx.GPXPoint
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for _, pt := range segment.Points {
+				if !pt.Timestamp.IsZero() {
+					res = append(res, pt)
+				}
+			}
+		}
+	}
+	return res
+}
+
+// localXY returns the (equirectangular) position of pt in meters, relative to origin
+func localXY(origin, pt gpx.GPXPoint) (float64, float64) {
+	return (pt.Longitude - origin.Longitude) * oneDegree * math.Cos(origin.Latitude*math.Pi/180), (pt.Latitude - origin.Latitude) * oneDegree
+}
+
+// matchPosition finds the position on the attempt (searching forward from the
+// point from) nearest to pt. Returns the (interpolated) number of seconds from
+// the attempt start and the index of the attempt point before the matched
+// position.
+func matchPosition(pt gpx.GPXPoint, attempt []gpx.GPXPoint, from int) (float64, int, bool) {
+	var (
+		bestDistance = math.MaxFloat64
+		bestSeconds  float64
+		bestIndex    int
+		searched     float64
+	)
+	for k := from; k < len(attempt)-1 && searched <= timeGapSearchDistance; k++ {
+		ax, ay := localXY(pt, attempt[k])
+		bx, by := localXY(pt, attempt[k+1])
+		dx, dy := bx-ax, by-ay
+		var f float64
+		if length2 := dx*dx + dy*dy; length2 > 0 {
+			f = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length2))
+		}
+		if distance := math.Hypot(ax+f*dx, ay+f*dy); distance < bestDistance {
+			seconds := attempt[k].Timestamp.Sub(attempt[0].Timestamp).Seconds()
+			bestDistance = distance
+			bestSeconds = seconds + f*attempt[k+1].Timestamp.Sub(attempt[k].Timestamp).Seconds()
+			bestIndex = k
+		}
+		searched += math.Hypot(dx, dy)
+	}
+	return bestSeconds, bestIndex, bestDistance <= maxTimeGapMatchDistance
+}
+
+// TimeGaps returns the time gap (in seconds) between the attempt and the reference at every reference
+// point (with X by XAxisMode). Positive values mean that the attempt is ahead of the reference. Points
+// are matched by position, reference points too far from the attempt are ignored.
+func (cs ChartService) TimeGaps(params ChartParams, reference, attempt gpx.GPX) ([]Point, *xAxisCounter) {
+	xc := newXAxisCounter(params, reference)
+	attemptPoints := pointsWithTime(attempt)
+	if len(attemptPoints) < 2 {
+		return nil, xc
+	}
+
+	var (
+		refStart *gpx.GPXPoint
+		from     int
+	)
+	line := newChartLine(params)
+	for trackNo, track := range reference.Tracks {
+		for segmentNo, segment := range track.Segments {
+			for n, pt := range segment.Points {
+				x, ok := xc.next(pt, n == 0)
+				if !ok || pt.Timestamp.IsZero() {
+					continue
+				}
+				if refStart == nil {
+					refStart = &segment.Points[n]
+				}
+				seconds, k, found := matchPosition(pt, attemptPoints, from)
+				if !found {
+					continue
+				}
+				from = k
+				line.add(Point{x, pt.Timestamp.Sub(refStart.Timestamp).Seconds() - seconds}, trackNo, segmentNo)
+			}
+		}
+	}
+	return line.points, xc
+}
+
+func (cs ChartService) prepareTimeGapAxis(axis *Axis, minGap, maxGap float64) {
+	axis.Formatter = func(f float64) string {
+		if f < 0 {
+			return "-" + FormatDuration(-f)
+		}
+		return "+" + FormatDuration(f)
+	}
+	g, l := timeAxisSteps(maxGap - minGap)
+	if axis.Grid == 0 {
+		axis.Grid = g
+	}
+	if axis.Labels == 0 {
+		axis.Labels = l
+	}
+}
+
+// TimeGapChart shows how far ahead (above zero) or behind (below zero) every attempt is compared
+// to the reference track (for example last year's personal best on the same route). With a single
+// attempt, the time ahead is filled with the (theme) fill color and the time behind in red.
+func (cs ChartService) TimeGapChart(c context.Context, params ChartParams, reference gpx.GPX, attempts []gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.timeGapChartParams(params, reference, attempts)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+func (cs ChartService) timeGapChartParams(params ChartParams, reference gpx.GPX, attempts []gpx.GPX) (ChartParams, error) {
+	if len(attempts) == 0 {
+		return params, errors.New("no attempts to compare")
+	}
+
+	var (
+		xc           *xAxisCounter
+		minGap       float64
+		maxGap       float64
+		attemptsGaps [][]Point
+	)
+	for _, attempt := range attempts {
+		var gaps []Point
+		gaps, xc = cs.TimeGaps(params, reference, attempt)
+		for _, gap := range gaps {
+			if isLineBreak(gap) {
+				continue
+			}
+			minGap, maxGap = math.Min(minGap, gap.Y), math.Max(maxGap, gap.Y)
+		}
+		attemptsGaps = append(attemptsGaps, gaps)
+	}
+
+	var series []Series
+	if len(attempts) == 1 {
+		series = append(series, Series{Points: attemptsGaps[0], Fill: true})
+		if params.NegativeFillColor == (color.RGBA{}) {
+			params.NegativeFillColor = timeGapBehindColor
+		}
+	} else {
+		if xc.found {
+			series = append(series, Series{Points: []Point{{xc.minX, 0}, {xc.maxX, 0}}, Color: timeGapZeroColor})
+		}
+		for n, gaps := range attemptsGaps {
+			lineColor := overlayColors[n%len(overlayColors)]
+			series = append(series, Series{Points: gaps, Color: lineColor, Label: gpxName(attempts[n], n)})
+		}
+	}
+	params.Series = append(series, params.Series...)
+
+	// Symmetric, so that zero is always visible:
+	max := math.Max(math.Max(-minGap, maxGap), 1)
+	if params.MinY == 0 && params.MaxY == 0 {
+		params.MinY, params.MaxY = -max, max
+	}
+	cs.prepareXAxis(&params, xc)
+	cs.prepareTimeGapAxis(&params.YAxis, -max, max)
+	return params, nil
+}
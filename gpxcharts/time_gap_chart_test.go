@@ -0,0 +1,103 @@
+package gpxcharts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// fasterCopy returns a copy of g, with all timestamps from the start (speeded up by the factor)
+func fasterCopy(g gpx.GPX, factor float64) gpx.GPX {
+	res := g
+	cloneTracks(&res)
+	var start time.Time
+	for i := range res.Tracks {
+		for j := range res.Tracks[i].Segments {
+			for k := range res.Tracks[i].Segments[j].Points {
+				pt := &res.Tracks[i].Segments[j].Points[k]
+				if start.IsZero() {
+					start = pt.Timestamp
+				}
+				pt.Timestamp = start.Add(time.Duration(float64(pt.Timestamp.Sub(start)) / factor))
+			}
+		}
+	}
+	return res
+}
+
+func TestTimeGaps(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/parenzana.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	gaps, _ := chartService.TimeGaps(ChartParams{}, *g, *g)
+	assert.Equal(t, g.GetTrackPointsNo(), len(gaps))
+	for _, gap := range gaps {
+		assert.InDelta(t, 0, gap.Y, 0.001)
+	}
+
+	faster := fasterCopy(*g, 1.25)
+	gaps, _ = chartService.TimeGaps(ChartParams{}, *g, faster)
+	if assert.True(t, len(gaps) > 0) {
+		duration := g.Duration()
+		// Finished in 80% of the reference time:
+		assert.InDelta(t, duration*0.2, gaps[len(gaps)-1].Y, 1)
+		for n := 1; n < len(gaps); n++ {
+			assert.True(t, gaps[n].Y >= gaps[n-1].Y-0.001)
+		}
+	}
+
+	gaps, _ = chartService.TimeGaps(ChartParams{}, faster, *g)
+	if assert.True(t, len(gaps) > 0) {
+		assert.True(t, gaps[len(gaps)-1].Y < 0)
+	}
+
+	empty, err := gpx.ParseFile("../test_files/empty.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	gaps, _ = chartService.TimeGaps(ChartParams{}, *g, *empty)
+	assert.Empty(t, gaps)
+}
+
+func TestTimeGapChart(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/parenzana.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	faster, slower := fasterCopy(*g, 1.1), fasterCopy(*g, 0.95)
+	faster.Name, slower.Name = "faster", "slower"
+
+	params, err := chartService.timeGapChartParams(ChartParams{}, *g, []gpx.GPX{faster})
+	assert.Nil(t, err)
+	gaps, _ := chartService.TimeGaps(ChartParams{}, *g, faster)
+	if assert.Equal(t, 1, len(params.Series)) {
+		assert.Equal(t, gaps, params.Series[0].Points)
+		assert.True(t, params.Series[0].Fill)
+	}
+	assert.Equal(t, LightTheme.Fill, params.fillColor(positive))
+	assert.Equal(t, timeGapBehindColor, params.fillColor(negative))
+	assert.Equal(t, -params.MaxY, params.MinY)
+	assert.True(t, params.MaxY >= gaps[len(gaps)-1].Y)
+
+	params, err = chartService.timeGapChartParams(ChartParams{}, *g, []gpx.GPX{faster, slower})
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(params.Series)) {
+		assert.Equal(t, timeGapZeroColor, params.Series[0].Color)
+		assert.Equal(t, 0., params.Series[0].Points[0].Y)
+		assert.Equal(t, "faster", params.Series[1].Label)
+		assert.Equal(t, "slower", params.Series[2].Label)
+		assert.False(t, params.Series[1].Fill)
+	}
+
+	_, err = chartService.TimeGapChart(context.Background(), ChartParams{Width: 900, Height: 200}, *g, nil, OutputPNG)
+	assert.NotNil(t, err)
+}
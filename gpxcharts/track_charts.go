@@ -3,17 +3,17 @@ package gpxcharts
 import (
 	"bytes"
 	"context"
-	"encoding/xml"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
 	"math"
-	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/llgcode/draw2d"
-	"github.com/llgcode/draw2d/draw2dimg"
 	"github.com/llgcode/draw2d/draw2dsvg"
 	"github.com/tkrajina/gpxgo/gpx"
 )
@@ -34,12 +34,37 @@ type Point struct {
 	X, Y float64
 }
 
+type AxisSeparator struct {
+	Value float64
+	Label string
+}
+
+type AxisPosition string
+
+const (
+	AxisLeft  AxisPosition = "left"
+	AxisRight AxisPosition = "right"
+)
+
 type Axis struct {
 	Show      bool
 	Grid      float64
 	Labels    float64
 	FontSize  float64
 	Formatter func(float64) string
+	// Separators are stronger grid lines with a label (for example day separators)
+	Separators []AxisSeparator
+	// Inverted (Y axis only) draws bigger values lower
+	Inverted bool
+	// Position (Y axis only) defaults to left for YAxis and right for Y2Axis
+	Position AxisPosition
+}
+
+func (a Axis) positionOr(def AxisPosition) AxisPosition {
+	if a.Position == "" {
+		return def
+	}
+	return a.Position
 }
 
 func (a Axis) formatterOrDefault() func(float64) string {
@@ -51,17 +76,214 @@ func (a Axis) formatterOrDefault() func(float64) string {
 	return a.Formatter
 }
 
+// Series is a line in the chart, optionally filled down to the baseline. Series are drawn in order.
+type Series struct {
+	Points []Point
+	// Color of the line, default (semi transparent) black
+	Color color.RGBA
+	// LineWidth defaults to ChartParams.LineWidth for filled series, and twice as much for others
+	LineWidth float64
+	// Fill with FillColor (NegativeFillColor for values below zero), both default to the ChartParams
+	// fill colors. FillColors[n] (if set for all Points) is the fill color between Points[n] and Points[n+1].
+	Fill              bool
+	FillColor         color.RGBA
+	NegativeFillColor color.RGBA
+	FillColors        []color.RGBA
+	// FillGradient (if set) is used instead of fill colors, defaults to ChartParams.FillGradient
+	FillGradient *Gradient
+	// Dash lengths (alternating dashes and gaps, in pixels), solid line if empty
+	Dash []float64
+	// Label is shown in the legend, EndLabel is drawn after the last point
+	Label    string
+	EndLabel string
+	// Y2 series are drawn with the Y2Axis scale
+	Y2 bool
+}
+
+// Bar is a rectangle (in chart coordinates), for bar charts and histograms
+type Bar struct {
+	MinX, MaxX float64
+	MinY, MaxY float64
+	Color      color.RGBA
+	// Label is drawn above the bar (or right of the bar, if Horizontal)
+	Label      string
+	Horizontal bool
+}
+
+// Marker is a dot (in chart coordinates) with an optional label
+type Marker struct {
+	X, Y  float64
+	Color color.RGBA
+	Label string
+	// Vertical markers also have a vertical line over the chart, with the label on top (staggered if
+	// labels overlap)
+	Vertical bool
+}
+
+// Span is a shaded X range (in chart coordinates) with an optional label on top
+type Span struct {
+	MinX, MaxX float64
+	Color      color.RGBA
+	Label      string
+	// Hatched spans have diagonal lines (in Color) instead of the fill
+	Hatched bool
+}
+
+// labelRows places labels in rows (from the top of the chart) so that they don't overlap
+type labelRows [][][2]float64
+
+// place returns the first row where the label (from left with the width) fits
+func (lr *labelRows) place(left, width float64) int {
+	for row, labels := range *lr {
+		fits := true
+		for _, label := range labels {
+			if left < label[1] && left+width > label[0] {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			(*lr)[row] = append(labels, [2]float64{left - 2, left + width + 2})
+			return row
+		}
+	}
+	*lr = append(*lr, [][2]float64{{left - 2, left + width + 2}})
+	return len(*lr) - 1
+}
+
+// LegendItem is a colored box with a label in the chart legend
+type LegendItem struct {
+	Color color.RGBA
+	Label string
+}
+
+type Corner string
+
+const (
+	CornerTopLeft     Corner = "top-left"
+	CornerTopRight    Corner = "top-right"
+	CornerBottomLeft  Corner = "bottom-left"
+	CornerBottomRight Corner = "bottom-right"
+)
+
+func AllCorners() []Corner {
+	return []Corner{
+		CornerTopLeft,
+		CornerTopRight,
+		CornerBottomLeft,
+		CornerBottomRight,
+	}
+}
+
 type Padding struct {
 	Top, Right, Bottom, Left float64
 }
 
+type XAxisMode string
+
+const (
+	XAxisDistance    XAxisMode = "distance"
+	XAxisElapsedTime XAxisMode = "elapsed"
+	XAxisMovingTime  XAxisMode = "moving"
+	XAxisClockTime   XAxisMode = "clock"
+)
+
+func AllXAxisModes() []XAxisMode {
+	return []XAxisMode{
+		XAxisDistance,
+		XAxisElapsedTime,
+		XAxisMovingTime,
+		XAxisClockTime,
+	}
+}
+
 type ChartParams struct {
 	Width, Height int
 	XAxis, YAxis  Axis
-	Points        []Point
-	FillColor     color.RGBA
-	Unit          UnitType
-	LineWidth     float64
+	// Series are prepared by the chart functions (the track chart is the first, other series are kept,
+	// except in map and panel charts where they are ignored)
+	Series  []Series
+	Bars    []Bar
+	Markers []Marker
+	Spans   []Span
+	// AxisFont is the font of axis labels (with the size of the axis FontSize, if set), TitleFont of titles,
+	// and AnnotationFont of span, marker, bar and separator labels, legend and stats boxes. The default size
+	// of titles and annotations is the size of X axis labels.
+	AxisFont, TitleFont, AnnotationFont Font
+	// Theme colors, LightTheme if not set
+	Theme Theme
+	// FillColor is the default fill color of filled series (overrides the theme fill colors)
+	FillColor color.RGBA
+	// NegativeFillColor is used for values below zero, defaults to FillColor
+	NegativeFillColor color.RGBA
+	// FillGradient is the default gradient fill of filled series (overrides fill colors)
+	FillGradient *Gradient
+	// TransparentBackground (also if the theme background is transparent)
+	TransparentBackground bool
+	// Legend items (series labels are added after them)
+	Legend []LegendItem
+	// LegendCorner defaults to top right
+	LegendCorner Corner
+	// Title is drawn in the top left corner of the chart
+	Title     string
+	Unit      UnitType
+	LineWidth float64
+
+	// XAxisMode defaults to distance. Time based modes use seconds for X, and
+	// ignore points without timestamps.
+	XAxisMode XAxisMode
+	// TimeZone for XAxisClockTime, defaults to UTC
+	TimeZone *time.Location
+	// PaceUnit defaults to the pace unit of Unit
+	PaceUnit PaceUnit
+	// VerticalSpeedWindow is the time window for vertical speed (VAM) smoothing, default 2min
+	VerticalSpeedWindow time.Duration
+	// AscentThreshold (in meters) is the hysteresis for cumulative ascent/descent (and stats), default
+	// DefaultAscentThreshold. Negative for no hysteresis, the result is then the same as gpx.UphillDownhill().
+	AscentThreshold float64
+	// GradeBuckets are the bucket edges (in percent) for GradeHistogramChart, default DefaultGradeBuckets
+	GradeBuckets []float64
+	// ColorByGrade fills the elevation chart with GradeColors (default DefaultGradeColors)
+	ColorByGrade bool
+	GradeColors  GradeColors
+	// Source (tracks or routes, by default routes only if there are no tracks) and SourceNo (the number
+	// of the track or route starting with 1, 0 for all) for all charts of a single GPX (and all chart
+	// types in panels and overlays)
+	Source   Source
+	SourceNo int
+	// ScaleBar and NorthArrow are drawn on MapChart
+	ScaleBar   bool
+	NorthArrow bool
+	// Climbs are shaded (colored by category) on the elevation chart
+	Climbs bool
+	// Waypoints (projected onto the track) are drawn on the elevation chart, waypoints farther than
+	// MaxWaypointDistance (in meters, if set) from the track are ignored
+	Waypoints           bool
+	MaxWaypointDistance float64
+	// GapPolicy (default GapConnect) defines how gaps between segments are drawn. With GapDistance the
+	// distance between segments is added to the distance X axis.
+	GapPolicy   GapPolicy
+	GapDistance bool
+	// Stops (periods with speeds below StopSpeed, default 1km/h, for at least StopDuration, default
+	// DefaultStopDuration) are shaded. With CollapseStops they are removed from the X axis, only with
+	// XAxisElapsedTime (ignored with other X axis modes).
+	Stops         bool
+	CollapseStops bool
+	StopSpeed     float64
+	StopDuration  time.Duration
+	// SplitDistance (in meters) for SplitsChart, defaults to 1km (1mi or 1NM, depending on Unit, but
+	// not on PaceUnit), SplitMetric defaults to SplitPace
+	SplitDistance float64
+	SplitMetric   SplitMetric
+	// Stats are shown in a box in the StatsCorner (default top left) of elevation and speed charts
+	Stats       []StatsField
+	StatsCorner Corner
+	// MinMaxMarkers marks the min and max elevation (or the top speed) on elevation and speed charts
+	MinMaxMarkers bool
+	// Sparkline is a compact (for example SparklineWidth×SparklineHeight) chart without axes, grid,
+	// labels and margins. SparklineMinMax adds dots at the min and max value.
+	Sparkline       bool
+	SparklineMinMax bool
 
 	ChartMargin  Padding
 	ChartPadding Padding
@@ -69,7 +291,15 @@ type ChartParams struct {
 	MinX, MaxX float64
 	MinY, MaxY float64
 
+	// Y2Axis is the secondary Y axis (for Y2 lines)
+	Y2Axis       Axis
+	MinY2, MaxY2 float64
+
 	invalid bool
+	// noBackground is used when more charts are rendered on the same image
+	noBackground bool
+	// statsBox lines (for Stats) are prepared by the chart functions
+	statsBox []LegendItem
 }
 
 func (cp ChartParams) UnitTypeOrMetric() UnitType {
@@ -79,14 +309,123 @@ func (cp ChartParams) UnitTypeOrMetric() UnitType {
 	return cp.Unit
 }
 
+func (cp ChartParams) XAxisModeOrDistance() XAxisMode {
+	if cp.XAxisMode == "" {
+		return XAxisDistance
+	}
+	return cp.XAxisMode
+}
+
+func (cp ChartParams) TimeZoneOrUTC() *time.Location {
+	if cp.TimeZone == nil {
+		return time.UTC
+	}
+	return cp.TimeZone
+}
+
+func (cp ChartParams) PaceUnitOrDefault() PaceUnit {
+	if cp.PaceUnit == "" {
+		return cp.UnitTypeOrMetric().PaceUnit()
+	}
+	return cp.PaceUnit
+}
+
+func (cp ChartParams) LegendCornerOrDefault() Corner {
+	if cp.LegendCorner == "" {
+		return CornerTopRight
+	}
+	return cp.LegendCorner
+}
+
+func (cp ChartParams) GapPolicyOrDefault() GapPolicy {
+	if cp.GapPolicy == "" {
+		return GapConnect
+	}
+	return cp.GapPolicy
+}
+
+func (cp ChartParams) StopSpeedOrDefault() float64 {
+	if cp.StopSpeed == 0 {
+		return movingSpeedThreshold
+	}
+	return cp.StopSpeed
+}
+
+func (cp ChartParams) StopDurationOrDefault() time.Duration {
+	if cp.StopDuration == 0 {
+		return DefaultStopDuration
+	}
+	return cp.StopDuration
+}
+
+func (cp ChartParams) SplitDistanceOrDefault() float64 {
+	if cp.SplitDistance <= 0 {
+		return cp.UnitTypeOrMetric().PaceUnit().Length()
+	}
+	return cp.SplitDistance
+}
+
+func (cp ChartParams) SplitMetricOrDefault() SplitMetric {
+	if cp.SplitMetric == "" {
+		return SplitPace
+	}
+	return cp.SplitMetric
+}
+
+func (cp ChartParams) StatsCornerOrDefault() Corner {
+	if cp.StatsCorner == "" {
+		return CornerTopLeft
+	}
+	return cp.StatsCorner
+}
+
+func (cp ChartParams) GradeColorsOrDefault() GradeColors {
+	if len(cp.GradeColors.Colors) == 0 {
+		return DefaultGradeColors
+	}
+	return cp.GradeColors
+}
+
+func (cp ChartParams) AscentThresholdOrDefault() float64 {
+	if cp.AscentThreshold < 0 {
+		return 0
+	}
+	if cp.AscentThreshold == 0 {
+		return DefaultAscentThreshold
+	}
+	return cp.AscentThreshold
+}
+
+func (cp ChartParams) VerticalSpeedWindowOrDefault() time.Duration {
+	if cp.VerticalSpeedWindow <= 0 {
+		return 2 * time.Minute
+	}
+	return cp.VerticalSpeedWindow
+}
+
 func (cp *ChartParams) prepare() {
 	if cp.LineWidth <= 0 {
 		cp.LineWidth = 0.5
 	}
 
+	var points, y2Points []Point
+	for _, series := range cp.Series {
+		if series.Y2 {
+			y2Points = append(y2Points, series.Points...)
+		} else {
+			points = append(points, series.Points...)
+		}
+	}
+	for _, bar := range cp.Bars {
+		points = append(points[:len(points):len(points)], Point{bar.MinX, bar.MinY}, Point{bar.MaxX, bar.MaxY})
+	}
+	for _, marker := range cp.Markers {
+		points = append(points[:len(points):len(points)], Point{marker.X, marker.Y})
+	}
+
 	if cp.MinX == 0 && cp.MaxX == 0 {
 		cp.MinX, cp.MaxX = math.MaxFloat64, -math.MaxFloat64
-		for _, p := range cp.Points {
+		for _, p := range points {
 			if p.X < cp.MinX {
 				cp.MinX = p.X
 			}
@@ -97,7 +436,7 @@ func (cp *ChartParams) prepare() {
 	}
 	if cp.MinY == 0 && cp.MaxY == 0 {
 		cp.MinY, cp.MaxY = math.MaxFloat64, -math.MaxFloat64
-		for _, p := range cp.Points {
+		for _, p := range points {
 			if p.Y > cp.MaxY {
 				cp.MaxY = p.Y
 			}
@@ -106,16 +445,135 @@ func (cp *ChartParams) prepare() {
 			}
 		}
 	}
+	if cp.MinY2 == 0 && cp.MaxY2 == 0 && len(y2Points) > 0 {
+		cp.MinY2, cp.MaxY2 = minMaxY(y2Points)
+	}
+	// Chart padding is in the units of the main Y axis, Y2 gets the same padding in pixels:
+	if cp.MaxY > cp.MinY {
+		k := (cp.MaxY2 - cp.MinY2) / (cp.MaxY - cp.MinY)
+		cp.MinY2 -= cp.ChartPadding.Bottom * k
+		cp.MaxY2 += cp.ChartPadding.Top * k
+	}
 	cp.MinX -= cp.ChartPadding.Left
 	cp.MaxX += cp.ChartPadding.Right
 	cp.MinY -= cp.ChartPadding.Bottom
 	cp.MaxY += cp.ChartPadding.Top
 }
 
+func (cp ChartParams) hasY2() bool {
+	return cp.MinY2 < cp.MaxY2 && !IsNanOrOnf(cp.MinY2) && !IsNanOrOnf(cp.MaxY2)
+}
+
 func (cp ChartParams) toImgCoords(x, y float64) (float64, float64) {
+	return cp.imgCoords(x, y, cp.MinY, cp.MaxY, cp.YAxis.Inverted)
+}
+
+// toImgCoordsY2 is toImgCoords for the Y2Axis values
+func (cp ChartParams) toImgCoordsY2(x, y float64) (float64, float64) {
+	return cp.imgCoords(x, y, cp.MinY2, cp.MaxY2, cp.Y2Axis.Inverted)
+}
+
+func (cp ChartParams) imgCoords(x, y, minY, maxY float64, inverted bool) (float64, float64) {
+	fromBottom := y - minY
+	if inverted {
+		fromBottom = maxY - y
+	}
 	rx := float64(cp.ChartMargin.Left) + float64(cp.Width-int(cp.ChartMargin.Left)-int(cp.ChartMargin.Right))*(x-cp.MinX)/(cp.MaxX-cp.MinX)
-	ry := float64(cp.Height-int(cp.ChartMargin.Bottom)) - float64(cp.Height-int(cp.ChartMargin.Bottom)-int(cp.ChartMargin.Top))*(y-cp.MinY)/(cp.MaxY-cp.MinY)
-	return rx, ry
+	ry := float64(cp.Height-int(cp.ChartMargin.Bottom)) - float64(cp.Height-int(cp.ChartMargin.Bottom)-int(cp.ChartMargin.Top))*fromBottom/(maxY-minY)
+	return limitImgCoord(rx), limitImgCoord(ry)
+}
+
+// maxImgCoord limits the coordinates of values far outside of the chart (the
+// rasterizer doesn't handle them)
+const maxImgCoord = 1e5
+
+func limitImgCoord(c float64) float64 {
+	return math.Max(-maxImgCoord, math.Min(maxImgCoord, c))
+}
+
+var defaultFillColor = color.RGBA{0x10, 0x10, 0x10, 0x40}
+
+// fillColor returns the fill color for positive or negative values
+func (cp ChartParams) fillColor(pn int) color.RGBA {
+	if pn == negative && cp.NegativeFillColor != (color.RGBA{}) {
+		return cp.NegativeFillColor
+	}
+	if cp.FillColor != (color.RGBA{}) {
+		return cp.FillColor
+	}
+	if pn == negative {
+		return cp.ThemeOrDefault().NegativeFill
+	}
+	return cp.ThemeOrDefault().Fill
+}
+
+func (cp ChartParams) ThemeOrDefault() Theme {
+	return cp.Theme.withDefaults(LightTheme)
+}
+
+// fillColor returns the fill color of the series (or the default from ChartParams) for positive or
+// negative values
+func (s Series) fillColor(params ChartParams, pn int) color.RGBA {
+	if pn == negative && s.NegativeFillColor != (color.RGBA{}) {
+		return s.NegativeFillColor
+	}
+	if s.FillColor != (color.RGBA{}) {
+		return s.FillColor
+	}
+	return params.fillColor(pn)
+}
+
+// fillGradient returns the gradient of the series, or the default ChartParams.FillGradient if the series
+// has no fill colors
+func (s Series) fillGradient(params ChartParams) *Gradient {
+	if s.FillGradient != nil {
+		return s.FillGradient
+	}
+	if s.FillColor == (color.RGBA{}) && len(s.FillColors) == 0 {
+		return params.FillGradient
+	}
+	return nil
+}
+
+// mainPoints are the points of the first series (for charts prepared by chart functions, the chart of
+// the track)
+func (cp ChartParams) mainPoints() []Point {
+	if len(cp.Series) == 0 {
+		return nil
+	}
+	return cp.Series[0].Points
+}
+
+// prependSeries adds the series (prepared by a chart function) before user series
+func (cp *ChartParams) prependSeries(series Series) {
+	cp.Series = append([]Series{series}, cp.Series...)
+}
+
+// legend returns the legend items and labels of series
+func (cp ChartParams) legend() []LegendItem {
+	items := cp.Legend
+	for _, series := range cp.Series {
+		if series.Label == "" {
+			continue
+		}
+		legendColor := series.Color
+		if series.Fill {
+			legendColor = series.fillColor(cp, positive)
+			if gradient := series.fillGradient(cp); gradient != nil {
+				legendColor = gradient.Top
+			}
+		}
+		items = append(items[:len(items):len(items)], LegendItem{Color: legendColor, Label: series.Label})
+	}
+	return items
+}
+
+// bottomY is the Y value drawn at the bottom of the chart
+func (cp ChartParams) bottomY() float64 {
+	if cp.YAxis.Inverted {
+		return cp.MaxY
+	}
+	return cp.MinY
 }
 
 const (
@@ -123,21 +581,137 @@ const (
 	negative
 )
 
+// Speeds below this are considered stopped (same as in gpxgo's MovingData)
+const movingSpeedThreshold = 1 * 1000. / 3600.
+
+// xAxisCounter computes X values (for the given XAxisMode) for all track
+// points, in order.
+type xAxisCounter struct {
+	mode        XAxisMode
+	tz          *time.Location
+	gapDistance bool
+	// stops are found only if needed (ChartParams.Stops or CollapseStops)
+	stops         []stop
+	stopNo        int
+	collapseStops bool
+
+	prev     *gpx.GPXPoint
+	distance float64
+	moving   float64
+	start    time.Time
+
+	found      bool
+	minX, maxX float64
+}
+
+func newXAxisCounter(params ChartParams, g gpx.GPX) *xAxisCounter {
+	xc := &xAxisCounter{
+		mode:          params.XAxisModeOrDistance(),
+		tz:            params.TimeZoneOrUTC(),
+		gapDistance:   params.GapDistance,
+		collapseStops: params.CollapseStops,
+	}
+	if params.Stops || params.CollapseStops {
+		xc.stops = findStops(params, g)
+	}
+	return xc
+}
+
+// next must be called for every point, newSegment is true for the first point
+// of every segment. Returns false if the point has no X value (missing time).
+func (xc *xAxisCounter) next(pt gpx.GPXPoint, newSegment bool) (float64, bool) {
+	if xc.prev != nil && !newSegment {
+		length := pt.Distance2D(xc.prev)
+		xc.distance += length
+		if !pt.Timestamp.IsZero() && !xc.prev.Timestamp.IsZero() {
+			if seconds := pt.Timestamp.Sub(xc.prev.Timestamp).Seconds(); seconds > 0 && length/seconds > movingSpeedThreshold {
+				xc.moving += seconds
+			}
+		}
+	} else if xc.prev != nil && xc.gapDistance {
+		xc.distance += pt.Distance2D(xc.prev)
+	}
+	xc.prev = &pt
+
+	var x float64
+	switch xc.mode {
+	case XAxisElapsedTime, XAxisMovingTime, XAxisClockTime:
+		if pt.Timestamp.IsZero() {
+			return 0, false
+		}
+		if xc.start.IsZero() {
+			xc.start = pt.Timestamp
+		}
+		switch xc.mode {
+		case XAxisElapsedTime:
+			x = pt.Timestamp.Sub(xc.start).Seconds()
+			if xc.collapseStops {
+				x -= stoppedBefore(xc.stops, pt.Timestamp)
+			}
+		case XAxisMovingTime:
+			x = xc.moving
+		default:
+			// Shifted to the time zone (with the offset of every point, so that DST changes are followed), so
+			// that the grid is aligned with local hours and days:
+			_, offset := pt.Timestamp.In(xc.tz).Zone()
+			x = float64(pt.Timestamp.UnixNano())/float64(time.Second) + float64(offset)
+		}
+	default:
+		x = xc.distance
+	}
+
+	for xc.stopNo < len(xc.stops) {
+		s := &xc.stops[xc.stopNo]
+		if pt.Timestamp.Equal(s.start) {
+			s.startX, s.hasStart = x, true
+		}
+		if pt.Timestamp.Before(s.end) {
+			break
+		}
+		if pt.Timestamp.Equal(s.end) {
+			s.endX, s.hasEnd = x, true
+		}
+		xc.stopNo++
+	}
+
+	if !xc.found || x < xc.minX {
+		xc.minX = x
+	}
+	if !xc.found || x > xc.maxX {
+		xc.maxX = x
+	}
+	xc.found = true
+	return x, true
+}
+
 type ErrorLogger interface {
 	Errorf(c context.Context, msg string, params ...interface{})
 }
 
 type ChartService struct {
-	FontDirs       []string
-	fontsFolderSet bool
-	Log            ErrorLogger
+	// FontDirs are the directories given to NewChartService (changing them has no effect).
+	//
+	// Deprecated: fonts are loaded with NewChartServiceWithFont, LoadFont or LoadFontFile.
+	FontDirs []string
+	fonts    *fontCache
+	Log      ErrorLogger
 }
 
+// NewChartService loads fonts (by draw2d font file names, the default is luxisr.ttf) from the first
+// existing directory in fontDirs
 func NewChartService(fontDirs []string) (*ChartService, error) {
-	cs := ChartService{
-		FontDirs: fontDirs,
+	cs := ChartService{FontDirs: fontDirs, fonts: newFontCache()}
+	if err := cs.fonts.setFontDir(cs.FontDirs); err != nil {
+		return nil, err
 	}
-	if err := cs.setFontFolder(); err != nil {
+	return &cs, nil
+}
+
+// NewChartServiceWithFont uses the TTF font (for example from go:embed or a generated Go file) as the
+// default font, more fonts can be loaded with LoadFont
+func NewChartServiceWithFont(font []byte) (*ChartService, error) {
+	cs := ChartService{fonts: newFontCache()}
+	if err := cs.LoadFont(DefaultFontFamily, FontRegular, font); err != nil {
 		return nil, err
 	}
 	return &cs, nil
@@ -149,31 +723,20 @@ func (cs *ChartService) Errorf(c context.Context, msg string, params ...interfac
 	}
 }
 
-func (cs *ChartService) setFontFolder() error {
-	if cs.fontsFolderSet {
-		return nil
-	}
-	for _, fontDir := range cs.FontDirs {
-		if _, err := os.Stat(fontDir); err == nil {
-			draw2d.SetFontFolder(fontDir)
-			cs.fontsFolderSet = true
-			return nil
-		}
-	}
-	return errors.New("no font dir found")
-}
-
 func (cs ChartService) invalidGraphParams(c context.Context, origParams ChartParams) ChartParams {
 	return ChartParams{
 		invalid: true,
 
-		Width:     origParams.Width,
-		Height:    origParams.Height,
-		XAxis:     Axis{Show: true},
-		YAxis:     Axis{Show: true},
-		FillColor: origParams.FillColor,
+		Width:  origParams.Width,
+		Height: origParams.Height,
+		XAxis:  Axis{Show: true},
+		YAxis:  Axis{Show: true},
 
 		ChartMargin: origParams.ChartMargin,
+		Title:       origParams.Title,
+
+		Theme:        origParams.Theme,
+		noBackground: origParams.noBackground,
 
 		MinX: 0,
 		MinY: 0,
@@ -251,6 +814,70 @@ func (cs ChartService) prepareSpeedAxis(axis *Axis, minSpeed, maxSpeed float64,
 	}
 }
 
+func (cs ChartService) preparePaceAxis(axis *Axis, minPace, maxPace float64, unit PaceUnit) {
+	axis.Formatter = func(f float64) string { return FormatPace(f/unit.Length(), unit) }
+	axis.Inverted = true
+	// Steps in seconds per unit:
+	steps := [][2]float64{
+		{1, 5},
+		{5, 10},
+		{5, 15},
+		{10, 30},
+		{15, 60},
+		{30, 2 * 60},
+		{60, 5 * 60},
+		{5 * 60, 10 * 60},
+	}
+	length := maxPace - minPace
+	g, l := 5*60., 15*60.
+	for _, step := range steps {
+		if length/step[1] <= 6 {
+			g, l = step[0], step[1]
+			break
+		}
+	}
+	if axis.Grid == 0 {
+		axis.Grid = g
+	}
+	if axis.Labels == 0 {
+		axis.Labels = l
+	}
+}
+
+func (cs ChartService) prepareVerticalSpeedAxis(axis *Axis, minSpeed, maxSpeed float64, unitType UnitType) {
+	axis.Formatter = func(f float64) string { return FormatVerticalSpeed(f, unitType) }
+	length := maxSpeed - minSpeed
+	var g, l float64
+	switch unitType {
+	case UnitTypeImperial, UnitTypeNautical:
+		if length <= ONE_FEET*1000 {
+			g, l = 100*ONE_FEET, 200*ONE_FEET
+		} else if length <= ONE_FEET*2500 {
+			g, l = 250*ONE_FEET, 500*ONE_FEET
+		} else if length <= ONE_FEET*5000 {
+			g, l = 500*ONE_FEET, 1000*ONE_FEET
+		} else {
+			g, l = 1000*ONE_FEET, 2000*ONE_FEET
+		}
+	default:
+		if length <= 500 {
+			g, l = 50, 100
+		} else if length <= 1000 {
+			g, l = 100, 200
+		} else if length <= 2500 {
+			g, l = 250, 500
+		} else {
+			g, l = 500, 1000
+		}
+	}
+	if axis.Grid == 0 {
+		axis.Grid = g
+	}
+	if axis.Labels == 0 {
+		axis.Labels = l
+	}
+}
+
 func (cs ChartService) prepareElevationAxis(axis *Axis, minEle, maxEle float64, unitType UnitType) {
 	length := maxEle - minEle
 
@@ -296,6 +923,43 @@ func (cs ChartService) prepareElevationAxis(axis *Axis, minEle, maxEle float64,
 	}
 }
 
+var extensionUnits = map[string]string{
+	"hr":    "bpm",
+	"cad":   "rpm",
+	"atemp": "°C",
+	"wtemp": "°C",
+	"power": "W",
+	"depth": "m",
+}
+
+func extensionUnit(field string) string {
+	return extensionUnits[strings.ToLower(localName(field))]
+}
+
+func (cs ChartService) prepareExtensionAxis(axis *Axis, field string, min, max float64) {
+	unit := extensionUnit(field)
+	axis.Formatter = func(f float64) string { return FormatFloat(f, 1) + unit }
+	length := max - min
+	step := 1.0
+	for step*10 < length {
+		step *= 10
+	}
+	var g, l float64
+	if length <= step*2 {
+		g, l = step/5, step/2.5
+	} else if length <= step*5 {
+		g, l = step/2, step
+	} else {
+		g, l = step, step*2
+	}
+	if axis.Grid == 0 {
+		axis.Grid = g
+	}
+	if axis.Labels == 0 {
+		axis.Labels = l
+	}
+}
+
 func (cs ChartService) prepareLengthAxis(axis *Axis, length float64, unitType UnitType) {
 	axis.Formatter = func(f float64) string { return FormatLength(f, unitType) }
 	var g, l float64
@@ -369,23 +1033,105 @@ func (cs ChartService) prepareLengthAxis(axis *Axis, length float64, unitType Un
 	}
 }
 
+func (cs ChartService) prepareDurationAxis(axis *Axis, seconds float64) {
+	axis.Formatter = func(f float64) string { return FormatDuration(f) }
+	g, l := timeAxisSteps(seconds)
+	if axis.Grid == 0 {
+		axis.Grid = g
+	}
+	if axis.Labels == 0 {
+		axis.Labels = l
+	}
+}
+
+func (cs ChartService) prepareClockAxis(axis *Axis, minX, maxX float64) {
+	axis.Formatter = func(f float64) string { return FormatClock(f) }
+	g, l := timeAxisSteps(maxX - minX)
+	if axis.Grid == 0 {
+		axis.Grid = g
+	}
+	if axis.Labels == 0 {
+		axis.Labels = l
+	}
+	for day := 86400 * math.Ceil(minX/86400); day < maxX; day += 86400 {
+		axis.Separators = append(axis.Separators, AxisSeparator{
+			Value: day,
+			Label: time.Unix(int64(day), 0).UTC().Format("Mon 2 Jan"),
+		})
+	}
+}
+
+// timeAxisSteps returns grid and label steps (in seconds)
+func timeAxisSteps(seconds float64) (float64, float64) {
+	steps := [][2]float64{
+		{10, 30},
+		{30, 60},
+		{60, 2 * 60},
+		{60, 5 * 60},
+		{5 * 60, 10 * 60},
+		{5 * 60, 15 * 60},
+		{10 * 60, 30 * 60},
+		{15 * 60, 3600},
+		{30 * 60, 2 * 3600},
+		{3600, 3 * 3600},
+		{2 * 3600, 6 * 3600},
+		{3 * 3600, 12 * 3600},
+	}
+	for _, step := range steps {
+		if seconds/step[1] <= 8 {
+			return step[0], step[1]
+		}
+	}
+	return 6 * 3600, 24 * 3600
+}
+
+func (cs ChartService) prepareXAxis(params *ChartParams, xc *xAxisCounter) {
+	if params.Stops {
+		params.Spans = append(params.Spans, stopSpans(xc.stops)...)
+	}
+	switch xc.mode {
+	case XAxisElapsedTime, XAxisMovingTime:
+		cs.prepareDurationAxis(&params.XAxis, xc.maxX)
+	case XAxisClockTime:
+		cs.prepareClockAxis(&params.XAxis, xc.minX, xc.maxX)
+	default:
+		cs.prepareLengthAxis(&params.XAxis, xc.maxX, params.UnitTypeOrMetric())
+	}
+}
+
+// Chart renders params.Series (and bars, spans and markers) as they are, with the range and axes set in
+// params. Use it to plot custom (derived) data.
+func (cs ChartService) Chart(c context.Context, params ChartParams, output OutputExtension) ([]byte, error) {
+	return cs.chart(c, params, output)
+}
+
 func (cs ChartService) chart(c context.Context, params ChartParams, output OutputExtension) ([]byte, error) {
+	return cs.draw(c, params, output, cs.renderChart)
+}
+
+func (cs ChartService) draw(c context.Context, params ChartParams, output OutputExtension, render func(context.Context, ChartParams, draw2d.GraphicContext)) ([]byte, error) {
 	var gc draw2d.GraphicContext
 	switch output {
 	case OutputPNG:
 		img := image.NewRGBA(image.Rect(0, 0, params.Width, params.Height))
-		gc := draw2dimg.NewGraphicContext(img)
-		cs.renderChart(c, params, gc)
+		gc := newImgGraphicContext(img)
+		if cs.fonts != nil {
+			gc.FontCache = cs.fonts
+		}
+		render(c, params, gc)
 		buf := new(bytes.Buffer)
 		if err := png.Encode(buf, img); err != nil {
 			return nil, fmt.Errorf("error encoding png %w", err)
 		}
 		return buf.Bytes(), nil
 	case OutputSVG:
-		svg := draw2dsvg.NewSvg()
-		gc = draw2dsvg.NewGraphicContext(svg)
-		cs.renderChart(c, params, gc)
-		bytes, err := xml.Marshal(svg)
+		svgGc := newSvgGraphicContext(draw2dsvg.NewSvg())
+		if cs.fonts != nil {
+			svgGc.FontCache = cs.fonts
+		}
+		gc = svgGc
+		render(c, params, gc)
+		bytes, err := svgGc.marshal()
 		if err != nil {
 			return nil, fmt.Errorf("error marshalling svg %w", err)
 		}
@@ -395,22 +1141,35 @@ func (cs ChartService) chart(c context.Context, params ChartParams, output Outpu
 	}
 }
 
-func (cs ChartService) renderChart(c context.Context, params ChartParams, gc draw2d.GraphicContext) {
-	// Initialize the graphic context on an RGBA image
-	//rect := image.Rect(0, 0, params.Width, params.Height)
-	//dest := image.NewRGBA(rect)
-
-	// Background:
+func (cs ChartService) renderBackground(params ChartParams, gc draw2d.GraphicContext) {
+	if params.TransparentBackground || params.Theme.TransparentBackground {
+		return
+	}
 	gc.BeginPath()
 	gc.MoveTo(0, 0)
-	gc.SetStrokeColor(color.RGBA{0xFF, 0xFF, 0xFF, 0xff})
+	gc.SetStrokeColor(params.ThemeOrDefault().Background)
+	gc.SetFillColor(params.ThemeOrDefault().Background)
 	gc.SetLineWidth(0)
 	gc.LineTo(float64(params.Width), 0.0)
 	gc.LineTo(float64(params.Width), float64(params.Height))
 	gc.LineTo(0, float64(params.Height))
 	gc.Close()
 	gc.FillStroke()
+}
+
+func (cs ChartService) renderChart(c context.Context, params ChartParams, gc draw2d.GraphicContext) {
+	// Initialize the graphic context on an RGBA image
+	//rect := image.Rect(0, 0, params.Width, params.Height)
+	//dest := image.NewRGBA(rect)
 
+	theme := params.ThemeOrDefault()
+	if !params.noBackground {
+		cs.renderBackground(params, gc)
+	}
+
+	if params.Sparkline {
+		params.sparkline()
+	}
 	params.prepare()
 	//fmt.Printf("params=%#v\n", params)
 
@@ -440,7 +1199,7 @@ func (cs ChartService) renderChart(c context.Context, params ChartParams, gc dra
 			}
 			gc.BeginPath()
 			gc.MoveTo(params.toImgCoords(v, params.MinY))
-			gc.SetStrokeColor(color.RGBA{0xE0, 0xE0, 0xE0, 0xff})
+			gc.SetStrokeColor(theme.Grid)
 			gc.SetLineWidth(params.LineWidth)
 			gc.LineTo(params.toImgCoords(v, params.MaxY))
 			gc.Close()
@@ -455,7 +1214,7 @@ func (cs ChartService) renderChart(c context.Context, params ChartParams, gc dra
 			}
 			gc.BeginPath()
 			gc.MoveTo(params.toImgCoords(params.MinX, v))
-			gc.SetStrokeColor(color.RGBA{0xE0, 0xE0, 0xE0, 0xff})
+			gc.SetStrokeColor(theme.Grid)
 			gc.SetLineWidth(params.LineWidth)
 			gc.LineTo(params.toImgCoords(params.MaxX, v))
 			gc.Close()
@@ -463,57 +1222,195 @@ func (cs ChartService) renderChart(c context.Context, params ChartParams, gc dra
 		}
 	}
 
-	// Graph:
-	for _, pn := range []int{positive, negative} {
-		for n, point := range params.Points {
-			x, y := point.X, point.Y
-			if n == 0 {
-				gc.BeginPath() // Initialize a new path
-				gc.MoveTo(params.toImgCoords(x, params.MinY))
-				gc.SetStrokeColor(color.RGBA{0x00, 0x00, 0x00, 0xaf})
-				gc.SetFillColor(color.RGBA{0x10, 0x10, 0x10, 0x40})
-				gc.SetLineWidth(params.LineWidth)
+	for _, separator := range params.XAxis.Separators {
+		if separator.Value < params.MinX || separator.Value > params.MaxX {
+			continue
+		}
+		gc.BeginPath()
+		gc.MoveTo(params.toImgCoords(separator.Value, params.MinY))
+		gc.SetStrokeColor(theme.Separator)
+		gc.SetLineWidth(params.LineWidth * 2)
+		gc.LineTo(params.toImgCoords(separator.Value, params.MaxY))
+		gc.Close()
+		gc.FillStroke()
+	}
+
+	for _, span := range params.Spans {
+		minX, maxX := math.Max(span.MinX, params.MinX), math.Min(span.MaxX, params.MaxX)
+		if minX >= maxX {
+			continue
+		}
+		x1, y1 := params.toImgCoords(minX, params.MinY)
+		x2, y2 := params.toImgCoords(maxX, params.MaxY)
+		if span.Hatched {
+			renderHatch(gc, math.Min(x1, x2), math.Min(y1, y2), math.Max(x1, x2), math.Max(y1, y2), span.Color)
+			continue
+		}
+		gc.BeginPath()
+		gc.SetFillColor(span.Color)
+		gc.MoveTo(x1, y1)
+		gc.LineTo(x2, y1)
+		gc.LineTo(x2, y2)
+		gc.LineTo(x1, y2)
+		gc.Close()
+		gc.Fill()
+	}
+
+	annotationFont := params.annotationFont()
+	fontSize := annotationFont.Size
+
+	for _, bar := range params.Bars {
+		x1, y1 := params.toImgCoords(bar.MinX, bar.MinY)
+		x2, y2 := params.toImgCoords(bar.MaxX, bar.MaxY)
+		gc.BeginPath()
+		gc.SetStrokeColor(theme.Stroke)
+		gc.SetFillColor(bar.Color)
+		gc.SetLineWidth(params.LineWidth)
+		gc.MoveTo(x1, y1)
+		gc.LineTo(x2, y1)
+		gc.LineTo(x2, y2)
+		gc.LineTo(x1, y2)
+		gc.Close()
+		gc.FillStroke()
+		if bar.Label != "" {
+			setFont(gc, annotationFont)
+			gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+			textWidth := gc.FillStringAt(bar.Label, x1, y2)
+			gc.SetFillColor(theme.Label)
+			if bar.Horizontal {
+				gc.FillStringAt(bar.Label, math.Max(x1, x2)+3, (y1+y2+fontSize)/2)
+			} else {
+				gc.FillStringAt(bar.Label, (x1+x2-textWidth)/2, math.Min(y1, y2)-3)
 			}
+		}
+	}
 
-			switch pn {
-			case positive:
-				if y < 0 {
-					y = 0
-				}
-			case negative:
-				if y > 0 {
-					y = 0
+	var endLabelsY []float64
+	for _, series := range params.Series {
+		if len(series.Points) == 0 || (series.Y2 && !params.hasY2()) {
+			continue
+		}
+		cs.renderSeries(params, gc, series)
+		if series.EndLabel != "" {
+			toImgCoords := params.toImgCoords
+			if series.Y2 {
+				toImgCoords = params.toImgCoordsY2
+			}
+			last := series.Points[len(series.Points)-1]
+			x, y := toImgCoords(last.X, last.Y)
+			setFont(gc, annotationFont)
+			gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+			textWidth := gc.FillStringAt(series.EndLabel, x, y)
+			// Above the line, but inside the chart and not over other labels:
+			y -= 3
+			if y-fontSize < params.ChartMargin.Top {
+				y = params.ChartMargin.Top + fontSize + 3
+			}
+			for _, labelY := range endLabelsY {
+				if math.Abs(labelY-y) < fontSize+2 {
+					y = labelY + fontSize + 2
 				}
 			}
+			endLabelsY = append(endLabelsY, y)
+			gc.SetFillColor(series.Color)
+			gc.FillStringAt(series.EndLabel, x-textWidth-2, y)
+		}
+	}
 
-			if y < params.MinY {
-				y = params.MinY
+	// Span and vertical marker labels:
+	var topLabels labelRows
+	for _, span := range params.Spans {
+		minX, maxX := math.Max(span.MinX, params.MinX), math.Min(span.MaxX, params.MaxX)
+		if minX >= maxX || span.Label == "" {
+			continue
+		}
+		x1, y1 := params.toImgCoords(minX, params.MinY)
+		x2, y2 := params.toImgCoords(maxX, params.MaxY)
+		setFont(gc, annotationFont)
+		gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+		textWidth := gc.FillStringAt(span.Label, x1, y1)
+		labelX := math.Max(params.ChartMargin.Left, (x1+x2-textWidth)/2)
+		labelX = math.Min(labelX, float64(params.Width)-params.ChartMargin.Right-textWidth)
+		row := topLabels.place(labelX, textWidth)
+		gc.SetFillColor(theme.Label)
+		gc.FillStringAt(span.Label, labelX, math.Min(y1, y2)+float64(row+1)*(fontSize+2))
+	}
+	for _, marker := range params.Markers {
+		x, y := params.toImgCoords(marker.X, marker.Y)
+		radius := math.Max(3, params.LineWidth*3)
+		if params.Sparkline {
+			radius = sparklineDotRadius
+		}
+		if marker.Vertical {
+			_, y1 := params.toImgCoords(marker.X, params.MinY)
+			_, y2 := params.toImgCoords(marker.X, params.MaxY)
+			top, bottom := math.Min(y1, y2), math.Max(y1, y2)
+			gc.BeginPath()
+			gc.SetStrokeColor(marker.Color)
+			gc.SetLineWidth(params.LineWidth)
+			gc.MoveTo(x, bottom)
+			gc.LineTo(x, top)
+			gc.Stroke()
+			if marker.Label != "" {
+				setFont(gc, annotationFont)
+				gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+				textWidth := gc.FillStringAt(marker.Label, x, top)
+				labelX := x + 2
+				if labelX+textWidth > float64(params.Width)-params.ChartMargin.Right {
+					// Left of the line if there is no space on the right:
+					labelX = x - 2 - textWidth
+				}
+				row := topLabels.place(labelX, textWidth)
+				gc.SetFillColor(marker.Color)
+				gc.FillStringAt(marker.Label, labelX, top+float64(row+1)*(fontSize+2))
 			}
-
-			gc.LineTo(params.toImgCoords(x, y))
-
-			if n == len(params.Points)-1 {
-				gc.LineTo(params.toImgCoords(x, math.Max(0.0, params.MinY)))
-				gc.LineTo(params.toImgCoords(0, math.Max(0.0, params.MinY)))
-				gc.Close()
-				gc.FillStroke()
+			gc.BeginPath()
+			gc.SetStrokeColor(theme.MarkerBorder)
+			gc.SetFillColor(marker.Color)
+			gc.SetLineWidth(params.LineWidth)
+			gc.MoveTo(x+radius, y)
+			gc.ArcTo(x, y, radius, radius, 0, 2*math.Pi)
+			gc.Close()
+			gc.FillStroke()
+			continue
+		}
+		gc.BeginPath()
+		gc.SetStrokeColor(theme.MarkerBorder)
+		gc.SetFillColor(marker.Color)
+		gc.SetLineWidth(params.LineWidth)
+		gc.MoveTo(x+radius, y)
+		gc.ArcTo(x, y, radius, radius, 0, 2*math.Pi)
+		gc.Close()
+		gc.FillStroke()
+		if marker.Label != "" {
+			setFont(gc, annotationFont)
+			gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+			textWidth := gc.FillStringAt(marker.Label, x, y)
+			labelX := x + radius + 2
+			if labelX+textWidth > float64(params.Width)-params.ChartMargin.Right {
+				labelX = x - radius - 2 - textWidth
 			}
+			// Inside the chart area:
+			_, y1 := params.toImgCoords(marker.X, params.MinY)
+			_, y2 := params.toImgCoords(marker.X, params.MaxY)
+			labelY := math.Max(math.Min(y1, y2)+fontSize, math.Min(math.Max(y1, y2), y+fontSize/2))
+			gc.SetFillColor(marker.Color)
+			gc.FillStringAt(marker.Label, labelX, labelY)
 		}
 	}
 
-	fontSize := 8.0
-	fontData := draw2d.FontData{Name: "luxi", Family: draw2d.FontFamilySerif, Style: draw2d.FontStyleBold | draw2d.FontStyleItalic}
-
-	axisColor := color.RGBA{0x36, 0x6a, 0xff, 0xff}
+	axisColor := theme.Axis
+	axisFont := params.AxisFont.orDefault(defaultFontSize)
 	if params.XAxis.Show {
 		if params.XAxis.FontSize > 0 {
-			fontSize = params.XAxis.FontSize
+			axisFont.Size = params.XAxis.FontSize
 		}
+		fontSize := axisFont.Size
 		gc.BeginPath()
-		gc.MoveTo(params.toImgCoords(params.MinX, params.MinY))
+		gc.MoveTo(params.toImgCoords(params.MinX, params.bottomY()))
 		gc.SetStrokeColor(axisColor)
 		gc.SetLineWidth(params.LineWidth)
-		gc.LineTo(params.toImgCoords(params.MaxX, params.MinY))
+		gc.LineTo(params.toImgCoords(params.MaxX, params.bottomY()))
 		gc.Close()
 		gc.FillStroke()
 		labels := params.XAxis.Labels
@@ -523,7 +1420,7 @@ func (cs ChartService) renderChart(c context.Context, params ChartParams, gc dra
 					continue
 				}
 				gc.BeginPath()
-				x, y := params.toImgCoords(v, params.MinY)
+				x, y := params.toImgCoords(v, params.bottomY())
 				gc.MoveTo(x, y-3)
 				gc.SetStrokeColor(axisColor)
 				gc.SetLineWidth(params.LineWidth)
@@ -531,83 +1428,400 @@ func (cs ChartService) renderChart(c context.Context, params ChartParams, gc dra
 				gc.Close()
 				gc.FillStroke()
 
-				gc.SetFontData(fontData)
-
 				txt := params.XAxis.formatterOrDefault()(v)
 
-				gc.SetFontData(fontData)
+				setFont(gc, axisFont)
 				gc.SetFillColor(color.RGBA{0, 0, 0, 0})
 				textWidth := gc.FillStringAt(txt, x-fontSize, float64(y)+float64(fontSize+4))
 
 				gc.SetFillColor(axisColor)
-				gc.SetFontSize(fontSize)
 				gc.FillStringAt(txt, x-textWidth/2, float64(y)+float64(fontSize+4))
 			}
 		}
 	}
 	if params.YAxis.Show {
 		if params.YAxis.FontSize > 0 {
-			fontSize = params.YAxis.FontSize
+			axisFont.Size = params.YAxis.FontSize
 		}
-		gc.BeginPath()
-		gc.MoveTo(params.toImgCoords(params.MinX, params.MinY))
-		gc.SetStrokeColor(axisColor)
-		gc.SetLineWidth(params.LineWidth)
-		gc.LineTo(params.toImgCoords(params.MinX, params.MaxY))
-		gc.Close()
-		gc.FillStroke()
-		labels := params.YAxis.Labels
-		if labels > 0 {
-			for v := labels * float64(int(params.MinY/labels)); v < params.MaxY; v += labels {
-				if v == 0 {
-					continue
-				}
-				if v < params.MinY {
-					continue
-				}
-				gc.BeginPath()
-				x, y := params.toImgCoords(params.MinX, v)
-				gc.MoveTo(x-3, y)
-				gc.SetStrokeColor(axisColor)
-				gc.SetLineWidth(params.LineWidth)
-				gc.LineTo(x+3, y)
-				gc.Close()
-				gc.FillStroke()
-
-				gc.SetFontSize(fontSize)
+		cs.renderYAxis(params, gc, params.YAxis, params.YAxis.positionOr(AxisLeft), params.MinY, params.MaxY, params.toImgCoords, axisColor, axisFont)
+	}
+	if params.Y2Axis.Show && params.hasY2() {
+		y2Font := axisFont
+		if params.Y2Axis.FontSize > 0 {
+			y2Font.Size = params.Y2Axis.FontSize
+		}
+		cs.renderYAxis(params, gc, params.Y2Axis, params.Y2Axis.positionOr(AxisRight), params.MinY2, params.MaxY2, params.toImgCoordsY2, axisColor, y2Font)
+	}
 
-				txt := params.YAxis.formatterOrDefault()(v)
-				gc.SetFontData(fontData)
-				gc.SetFillColor(color.RGBA{0, 0, 0, 0})
-				textWidth := gc.FillStringAt(txt, x-fontSize, float64(y)+float64(fontSize+4))
+	for _, separator := range params.XAxis.Separators {
+		if separator.Value < params.MinX || separator.Value > params.MaxX || separator.Label == "" {
+			continue
+		}
+		x, y := params.toImgCoords(separator.Value, params.MaxY)
+		setFont(gc, annotationFont)
+		gc.SetFillColor(theme.Separator)
+		gc.FillStringAt(separator.Label, x+3, y+fontSize+2)
+	}
 
-				gc.SetFillColor(axisColor)
-				gc.FillStringAt(txt, x-textWidth-fontSize/2, y+fontSize/2)
-			}
+	if params.Title != "" {
+		x, y := params.toImgCoords(params.MinX, params.MaxY)
+		if params.YAxis.Inverted {
+			x, y = params.toImgCoords(params.MinX, params.MinY)
 		}
+		titleFont := params.TitleFont.orDefault(params.labelFontSize())
+		setFont(gc, titleFont)
+		gc.SetFillColor(theme.Label)
+		gc.FillStringAt(params.Title, x+4, y+titleFont.Size+2)
+	}
+
+	if legend := params.legend(); len(legend) > 0 && !params.invalid {
+		cs.renderBox(params, gc, params.LegendCornerOrDefault(), legend, annotationFont)
+	}
+	if len(params.statsBox) > 0 && !params.invalid {
+		cs.renderBox(params, gc, params.StatsCornerOrDefault(), params.statsBox, annotationFont)
 	}
 
 	if params.invalid {
 		x, y := params.toImgCoords((params.MinX+params.MaxX)/2, (params.MinY+params.MaxY)/2)
 		txt := "No enough data available"
-		gc.SetFontData(fontData)
+		setFont(gc, annotationFont)
 		gc.SetFillColor(color.RGBA{0, 0, 0, 0})
 		textWidth := gc.FillStringAt(txt, x-fontSize, float64(y)+float64(fontSize+4))
-		gc.SetFillColor(color.RGBA{0xff, 0x4e, 0x00, 0xff})
+		gc.SetFillColor(theme.Invalid)
 		gc.FillStringAt(txt, x-textWidth/2, float64(y)+float64(fontSize+4))
 	}
 }
 
-func (cs ChartService) SpeedChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
-	g.ReduceTrackPoints(1000, 50)
-	var points []Point
-	var minSpeed, maxSpeed float64
-	var d float64
-	for _, track := range g.Tracks {
-		for _, segment := range track.Segments {
+func (cs ChartService) renderYAxis(params ChartParams, gc draw2d.GraphicContext, axis Axis, position AxisPosition, minY, maxY float64, toImgCoords func(x, y float64) (float64, float64), axisColor color.RGBA, font Font) {
+	fontSize := font.Size
+	axisX := params.MinX
+	if position == AxisRight {
+		axisX = params.MaxX
+	}
+	gc.BeginPath()
+	gc.MoveTo(toImgCoords(axisX, minY))
+	gc.SetStrokeColor(axisColor)
+	gc.SetLineWidth(params.LineWidth)
+	gc.LineTo(toImgCoords(axisX, maxY))
+	gc.Close()
+	gc.FillStroke()
+	labels := axis.Labels
+	if labels > 0 {
+		for v := labels * float64(int(minY/labels)); v < maxY; v += labels {
+			if v == 0 {
+				continue
+			}
+			if v < minY {
+				continue
+			}
+			gc.BeginPath()
+			x, y := toImgCoords(axisX, v)
+			gc.MoveTo(x-3, y)
+			gc.SetStrokeColor(axisColor)
+			gc.SetLineWidth(params.LineWidth)
+			gc.LineTo(x+3, y)
+			gc.Close()
+			gc.FillStroke()
+
+			txt := axis.formatterOrDefault()(v)
+			setFont(gc, font)
+			gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+			textWidth := gc.FillStringAt(txt, x-fontSize, float64(y)+float64(fontSize+4))
+
+			gc.SetFillColor(axisColor)
+			if position == AxisRight {
+				gc.FillStringAt(txt, x+fontSize/2, y+fontSize/2)
+			} else {
+				gc.FillStringAt(txt, x-textWidth-fontSize/2, y+fontSize/2)
+			}
+		}
+	}
+}
+
+// renderSeries fills (if Fill) and draws the line of the series
+func (cs ChartService) renderSeries(params ChartParams, gc draw2d.GraphicContext, series Series) {
+	toImgCoords, minY, maxY, inverted := params.toImgCoords, params.MinY, params.MaxY, params.YAxis.Inverted
+	if series.Y2 {
+		toImgCoords, minY, maxY, inverted = params.toImgCoordsY2, params.MinY2, params.MaxY2, params.Y2Axis.Inverted
+	}
+	strokeColor := series.Color
+	if strokeColor == (color.RGBA{}) {
+		strokeColor = params.ThemeOrDefault().Stroke
+	}
+	lineWidth := series.LineWidth
+	if lineWidth <= 0 {
+		lineWidth = params.LineWidth
+		if !series.Fill {
+			lineWidth *= 2
+		}
+	}
+	gc.SetLineDash(series.Dash, 0)
+	defer gc.SetLineDash(nil, 0)
+
+	strokeLine := func() {
+		gc.BeginPath()
+		gc.SetStrokeColor(strokeColor)
+		gc.SetLineWidth(lineWidth)
+		for n, point := range series.Points {
+			if isLineBreak(point) {
+				continue
+			}
+			if n == 0 || isLineBreak(series.Points[n-1]) {
+				gc.MoveTo(toImgCoords(point.X, point.Y))
+			} else {
+				gc.LineTo(toImgCoords(point.X, point.Y))
+			}
+		}
+		gc.Stroke()
+	}
+	if !series.Fill {
+		strokeLine()
+		return
+	}
+
+	baseline := math.Max(0.0, minY)
+	if inverted {
+		baseline = maxY
+	}
+	if gradient := series.fillGradient(params); gradient != nil {
+		_, top := toImgCoords(0, maxY)
+		_, bottom := toImgCoords(0, minY)
+		for _, points := range splitLines(series.Points) {
+			gc.BeginPath()
+			gc.MoveTo(toImgCoords(points[0].X, baseline))
+			for _, point := range points {
+				gc.LineTo(toImgCoords(point.X, math.Max(minY, math.Min(maxY, point.Y))))
+			}
+			gc.LineTo(toImgCoords(points[len(points)-1].X, baseline))
+			gc.Close()
+			if filler, ok := gc.(gradientFiller); ok {
+				filler.fillGradient(*gradient, math.Min(top, bottom), math.Max(top, bottom))
+			} else {
+				gc.SetFillColor(gradient.Top)
+				gc.Fill()
+			}
+		}
+		strokeLine()
+		return
+	}
+	if len(series.FillColors) == len(series.Points) {
+		cs.renderColoredFill(series, gc, toImgCoords, baseline, minY, strokeColor, lineWidth)
+		if inverted {
+			return
+		}
+	}
+	for _, pn := range []int{positive, negative} {
+		if pn == negative && inverted {
+			continue
+		}
+		if pn == positive && len(series.FillColors) == len(series.Points) {
+			continue
+		}
+		// Every part between line breaks is filled (and closed to its first X) separately:
+		for _, points := range splitLines(series.Points) {
+			for n, point := range points {
+				x, y := point.X, point.Y
+				if n == 0 {
+					gc.BeginPath() // Initialize a new path
+					gc.MoveTo(toImgCoords(x, baseline))
+					gc.SetStrokeColor(strokeColor)
+					gc.SetFillColor(series.fillColor(params, pn))
+					gc.SetLineWidth(lineWidth)
+				}
+
+				switch pn {
+				case positive:
+					if y < 0 {
+						y = 0
+					}
+				case negative:
+					if y > 0 {
+						y = 0
+					}
+				}
+
+				if y < minY {
+					y = minY
+				}
+				if inverted && y > maxY {
+					y = maxY
+				}
+
+				gc.LineTo(toImgCoords(x, y))
+
+				if n == len(points)-1 {
+					gc.LineTo(toImgCoords(x, baseline))
+					gc.LineTo(toImgCoords(points[0].X, baseline))
+					gc.Close()
+					gc.FillStroke()
+				}
+			}
+		}
+	}
+}
+
+// renderColoredFill fills the area below the series points with FillColors, every run of
+// the same color is filled as one polygon
+func (cs ChartService) renderColoredFill(series Series, gc draw2d.GraphicContext, toImgCoords func(x, y float64) (float64, float64), baseline, minY float64, strokeColor color.RGBA, lineWidth float64) {
+	points := series.Points
+	y := func(n int) float64 {
+		return math.Max(math.Max(points[n].Y, 0), minY)
+	}
+	for from := 0; from < len(points)-1; {
+		if isLineBreak(points[from+1]) {
+			from += 2
+			continue
+		}
+		to := from + 1
+		for to < len(points)-1 && !isLineBreak(points[to+1]) && series.FillColors[to] == series.FillColors[from] {
+			to++
+		}
+		gc.BeginPath()
+		// Stroked with the same color to avoid gaps between polygons:
+		gc.SetStrokeColor(series.FillColors[from])
+		gc.SetFillColor(series.FillColors[from])
+		gc.SetLineWidth(0.5)
+		gc.MoveTo(toImgCoords(points[from].X, baseline))
+		for n := from; n <= to; n++ {
+			gc.LineTo(toImgCoords(points[n].X, y(n)))
+		}
+		gc.LineTo(toImgCoords(points[to].X, baseline))
+		gc.Close()
+		gc.FillStroke()
+		from = to
+	}
+
+	gc.BeginPath()
+	gc.SetStrokeColor(strokeColor)
+	gc.SetLineWidth(lineWidth)
+	for n, point := range points {
+		if isLineBreak(point) {
+			continue
+		}
+		if n == 0 || isLineBreak(points[n-1]) {
+			gc.MoveTo(toImgCoords(point.X, y(n)))
+		} else {
+			gc.LineTo(toImgCoords(point.X, y(n)))
+		}
+	}
+	gc.Stroke()
+}
+
+// renderHatch draws diagonal lines in the rectangle (in image coordinates)
+func renderHatch(gc draw2d.GraphicContext, left, top, right, bottom float64, col color.RGBA) {
+	const spacing = 6.
+	gc.BeginPath()
+	gc.SetStrokeColor(col)
+	gc.SetLineWidth(1)
+	// Lines are x+y=c:
+	for c := left + top + spacing; c < right+bottom; c += spacing {
+		y1, y2 := math.Min(bottom, c-left), math.Max(top, c-right)
+		gc.MoveTo(c-y1, y1)
+		gc.LineTo(c-y2, y2)
+	}
+	gc.Stroke()
+}
+
+// renderBox renders the items (the colored box is omitted for items without color) in a box in the corner
+func (cs ChartService) renderBox(params ChartParams, gc draw2d.GraphicContext, corner Corner, items []LegendItem, font Font) {
+	const (
+		margin  = 5.
+		padding = 4.
+	)
+	fontSize := font.Size
+	setFont(gc, font)
+	gc.SetFillColor(color.RGBA{0, 0, 0, 0})
+	var (
+		textWidth  float64
+		colorWidth float64
+	)
+	for _, item := range items {
+		textWidth = math.Max(textWidth, gc.FillStringAt(item.Label, 0, 0))
+		if item.Color != (color.RGBA{}) {
+			colorWidth = fontSize + 4
+		}
+	}
+	rowHeight := fontSize + 4
+	width := 2*padding + colorWidth + textWidth
+	height := 2*padding + rowHeight*float64(len(items)) - 4
+
+	left, top := params.toImgCoords(params.MinX, params.MaxY)
+	right, bottom := params.toImgCoords(params.MaxX, params.MinY)
+	if params.YAxis.Inverted {
+		top, bottom = bottom, top
+	}
+	x, y := left+margin, top+margin
+	switch corner {
+	case CornerTopRight:
+		x = right - margin - width
+	case CornerBottomLeft:
+		y = bottom - margin - height
+	case CornerBottomRight:
+		x, y = right-margin-width, bottom-margin-height
+	}
+
+	gc.BeginPath()
+	theme := params.ThemeOrDefault()
+	gc.SetStrokeColor(theme.BoxBorder)
+	gc.SetFillColor(theme.BoxBackground)
+	gc.SetLineWidth(params.LineWidth)
+	gc.MoveTo(x, y)
+	gc.LineTo(x+width, y)
+	gc.LineTo(x+width, y+height)
+	gc.LineTo(x, y+height)
+	gc.Close()
+	gc.FillStroke()
+
+	for n, item := range items {
+		boxX, boxY := x+padding, y+padding+float64(n)*rowHeight
+		gc.SetFillColor(theme.Label)
+		if item.Color == (color.RGBA{}) {
+			gc.FillStringAt(item.Label, boxX+colorWidth, boxY+fontSize)
+			continue
+		}
+		gc.BeginPath()
+		gc.SetStrokeColor(theme.Stroke)
+		gc.SetFillColor(item.Color)
+		gc.SetLineWidth(params.LineWidth)
+		gc.MoveTo(boxX, boxY)
+		gc.LineTo(boxX+fontSize, boxY)
+		gc.LineTo(boxX+fontSize, boxY+fontSize)
+		gc.LineTo(boxX, boxY+fontSize)
+		gc.Close()
+		gc.FillStroke()
+		gc.SetFillColor(theme.Label)
+		gc.FillStringAt(item.Label, boxX+colorWidth, boxY+fontSize)
+	}
+}
+
+func minMaxY(points []Point) (float64, float64) {
+	var (
+		min, max float64
+		found    bool
+	)
+	for _, pt := range points {
+		if isLineBreak(pt) {
+			continue
+		}
+		if !found || pt.Y < min {
+			min = pt.Y
+		}
+		if !found || pt.Y > max {
+			max = pt.Y
+		}
+		found = true
+	}
+	return min, max
+}
+
+// forEachSpeed calls fn for every track point with speed (in m/s, computed from the previous and next point)
+func forEachSpeed(g gpx.GPX, xc *xAxisCounter, fn func(x, speed float64, trackNo, segmentNo, pointNo int)) {
+	for trackNo, track := range g.Tracks {
+		for segmentNo, segment := range track.Segments {
 			for n, pt := range segment.Points {
-				if 0 < n {
-					d += pt.Distance2D(&segment.Points[n-1])
+				x, ok := xc.next(pt, n == 0)
+				if !ok {
+					continue
 				}
 				if 0 < n && n < len(segment.Points)-1 {
 					prevPt := segment.Points[n-1]
@@ -616,80 +1830,577 @@ func (cs ChartService) SpeedChart(c context.Context, params ChartParams, g gpx.G
 					if !prevPt.Timestamp.IsZero() && !pt.Timestamp.IsZero() && !nextPt.Timestamp.IsZero() {
 						duration := nextPt.Timestamp.Sub(prevPt.Timestamp)
 						length := nextPt.Distance2D(&pt) + pt.Distance2D(&prevPt)
-						speed := length / duration.Seconds()
-						if len(points) == 0 || speed < minSpeed {
-							minSpeed = speed
-						}
-						if len(points) == 0 || speed > maxSpeed {
-							maxSpeed = speed
-						}
-						points = append(points, Point{d, speed})
+						fn(x, length/duration.Seconds(), trackNo, segmentNo, n)
+					}
+				}
+			}
+		}
+	}
+}
+
+// isReducedPoint is true if the point from the reduced (or smoothed) track is the original point
+func isReducedPoint(pt, reduced gpx.GPXPoint) bool {
+	return pt.Latitude == reduced.Latitude && pt.Longitude == reduced.Longitude && pt.Timestamp.Equal(reduced.Timestamp)
+}
+
+// reducedPointsX returns X values of the reduced track points (which must be a
+// subset of the original points), computed on the original track. So that
+// charts computed on reduced tracks have the same X axis as the others. NaN
+// is used for points without an X value.
+func reducedPointsX(xc *xAxisCounter, original, reduced gpx.GPX) [][][]float64 {
+	res := make([][][]float64, len(original.Tracks))
+	for trackNo, track := range original.Tracks {
+		res[trackNo] = make([][]float64, len(track.Segments))
+		for segmentNo, segment := range track.Segments {
+			reducedPoints := reduced.Tracks[trackNo].Segments[segmentNo].Points
+			xs := make([]float64, len(reducedPoints))
+			j := 0
+			for n, pt := range segment.Points {
+				x, ok := xc.next(pt, n == 0)
+				if j < len(reducedPoints) && isReducedPoint(pt, reducedPoints[j]) {
+					if !ok {
+						x = math.NaN()
 					}
+					xs[j] = x
+					j++
 				}
 			}
+			for ; j < len(reducedPoints); j++ {
+				xs[j] = math.NaN()
+			}
+			res[trackNo][segmentNo] = xs
 		}
 	}
-	params.Points = points
-	cs.prepareLengthAxis(&params.XAxis, d, params.UnitTypeOrMetric())
+	return res
+}
+
+// speedPoints returns speeds (m/s) for all track points with timestamps
+func (cs ChartService) speedPoints(params ChartParams, g gpx.GPX) (*chartLine, *xAxisCounter) {
+	reduced := g
+	cloneTracks(&reduced)
+	reduced.ReduceTrackPoints(1000, 50)
+	xc := newXAxisCounter(params, g)
+	xs := reducedPointsX(xc, g, reduced)
+	line := newChartLine(params)
+	forEachSpeed(reduced, newXAxisCounter(params, reduced), func(_, speed float64, trackNo, segmentNo, pointNo int) {
+		if x := xs[trackNo][segmentNo][pointNo]; !math.IsNaN(x) {
+			line.add(Point{x, speed}, trackNo, segmentNo)
+		}
+	})
+	return line, xc
+}
+
+func (cs ChartService) speedChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return params, err
+	}
+	line, xc := cs.speedPoints(params, g)
+	points := line.points
+	minSpeed, maxSpeed := minMaxY(points)
+	params.prependSeries(Series{Points: points, Fill: true})
+	params.Spans = append(params.Spans, line.gaps...)
+	if params.MinMaxMarkers {
+		params.Markers = append(params.Markers, minMaxMarkers(points, false, func(f float64) string { return FormatSpeed(f, params.UnitTypeOrMetric(), false) })...)
+	}
+	params.statsBox = cs.statsBox(params, g)
+	cs.prepareXAxis(&params, xc)
 	cs.prepareSpeedAxis(&params.YAxis, minSpeed, maxSpeed, params.UnitTypeOrMetric())
+	return params, nil
+}
+
+func (cs ChartService) SpeedChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.speedChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
 	return cs.chart(c, params, output)
 }
 
-func (cs ChartService) SteepnessChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+// Paces slower than this times the median pace are clamped (stops would make the scale useless)
+const maxPaceToMedian = 2.5
+
+func (cs ChartService) paceChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return params, err
+	}
+	line, xc := cs.speedPoints(params, g)
+	speeds := line.points
+	unitLength := params.PaceUnitOrDefault().Length()
+
+	var paces []float64
+	for _, pt := range speeds {
+		if pt.Y > 0 && !IsNanOrOnf(pt.Y) {
+			paces = append(paces, unitLength/pt.Y)
+		}
+	}
+	sort.Float64s(paces)
+	var maxPace float64
+	if len(paces) > 0 {
+		maxPace = maxPaceToMedian * paces[len(paces)/2]
+	}
+
+	var points []Point
+	for _, pt := range speeds {
+		if isLineBreak(pt) {
+			points = append(points, pt)
+			continue
+		}
+		pace := maxPace
+		if pt.Y > 0 && unitLength/pt.Y < maxPace {
+			pace = unitLength / pt.Y
+		}
+		points = append(points, Point{pt.X, pace})
+	}
+
+	minPace, maxPace := minMaxY(points)
+	params.prependSeries(Series{Points: points, Fill: true})
+	params.Spans = append(params.Spans, line.gaps...)
+	cs.prepareXAxis(&params, xc)
+	cs.preparePaceAxis(&params.YAxis, minPace, maxPace, params.PaceUnitOrDefault())
+	return params, nil
+}
+
+// PaceChart is a speed chart with pace (in seconds per PaceUnit) and inverted Y axis (faster is higher)
+func (cs ChartService) PaceChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.paceChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+func (cs ChartService) verticalSpeedChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return params, err
+	}
+	halfWindow := params.VerticalSpeedWindowOrDefault() / 2
+	line := newChartLine(params)
+	xc := newXAxisCounter(params, g)
+	for trackNo, track := range g.Tracks {
+		for segmentNo, segment := range track.Segments {
+			from, to := 0, 0
+			for n, pt := range segment.Points {
+				x, ok := xc.next(pt, n == 0)
+				if !ok || pt.Timestamp.IsZero() || pt.Elevation.Null() {
+					continue
+				}
+				for from < n && pt.Timestamp.Sub(segment.Points[from].Timestamp) > halfWindow {
+					from++
+				}
+				if to < n {
+					to = n
+				}
+				for to+1 < len(segment.Points) && segment.Points[to+1].Timestamp.Sub(pt.Timestamp) <= halfWindow {
+					to++
+				}
+				fromPt, toPt := segment.Points[from], segment.Points[to]
+				if fromPt.Timestamp.IsZero() || toPt.Timestamp.IsZero() || fromPt.Elevation.Null() || toPt.Elevation.Null() {
+					continue
+				}
+				seconds := toPt.Timestamp.Sub(fromPt.Timestamp).Seconds()
+				if seconds <= 0 {
+					continue
+				}
+				line.add(Point{x, (toPt.Elevation.Value() - fromPt.Elevation.Value()) / seconds * 3600}, trackNo, segmentNo)
+			}
+		}
+	}
+
+	minSpeed, maxSpeed := minMaxY(line.points)
+	params.prependSeries(Series{Points: line.points, Fill: true})
+	params.Spans = append(params.Spans, line.gaps...)
+	cs.prepareXAxis(&params, xc)
+	cs.prepareVerticalSpeedAxis(&params.YAxis, minSpeed, maxSpeed, params.UnitTypeOrMetric())
+	return params, nil
+}
+
+// VerticalSpeedChart shows the vertical speed (VAM, in meters per hour), smoothed over VerticalSpeedWindow
+func (cs ChartService) VerticalSpeedChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.verticalSpeedChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+// DefaultAscentThreshold (in meters) filters out GPS elevation noise from the cumulative ascent/descent
+const DefaultAscentThreshold = 3.0
+
+// cumulativeAscentDescent returns the cumulative ascent and descent for every point with elevation.
+// Elevations are smoothed in the same way as in gpx.UphillDownhill(), but changes are counted only
+// when the elevation moves AscentThreshold away from the last counted elevation. The end values are
+// never more than gpx.UphillDownhill(), and ascent minus descent (of every segment) differs from its
+// uphill minus downhill by less than the threshold.
+func (cs ChartService) cumulativeAscentDescent(params ChartParams, g gpx.GPX) (*chartLine, *chartLine, *xAxisCounter) {
+	var ascent, descent float64
+	threshold := params.AscentThresholdOrDefault()
+	ascentLine, descentLine := newChartLine(params), newChartLine(params)
+	xc := newXAxisCounter(params, g)
+	for trackNo, track := range g.Tracks {
+		for segmentNo, segment := range track.Segments {
+			elevations := segment.Elevations()
+			var (
+				ref    float64
+				hasRef bool
+			)
+			for n, pt := range segment.Points {
+				x, ok := xc.next(pt, n == 0)
+				if !ok || elevations[n].Null() {
+					continue
+				}
+				ele := elevations[n].Value()
+				if 0 < n && n < len(elevations)-1 && elevations[n-1].NotNull() && elevations[n+1].NotNull() {
+					ele = elevations[n-1].Value()*0.3 + ele*0.4 + elevations[n+1].Value()*0.3
+				}
+				if !hasRef {
+					ref, hasRef = ele, true
+				} else if ele-ref >= threshold {
+					ascent += ele - ref
+					ref = ele
+				} else if ref-ele >= threshold {
+					descent += ref - ele
+					ref = ele
+				}
+				ascentLine.add(Point{x, ascent}, trackNo, segmentNo)
+				descentLine.add(Point{x, descent}, trackNo, segmentNo)
+			}
+		}
+	}
+	return ascentLine, descentLine, xc
+}
+
+func (cs ChartService) AscentDescentChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return nil, err
+	}
+	ascentLine, descentLine, xc := cs.cumulativeAscentDescent(params, g)
+	ascent, descent := ascentLine.points, descentLine.points
+	unitType := params.UnitTypeOrMetric()
+	var max float64
+	params.Spans = append(params.Spans, ascentLine.gaps...)
+	if len(ascent) > 0 {
+		lastAscent, lastDescent := ascent[len(ascent)-1].Y, descent[len(descent)-1].Y
+		max = math.Max(lastAscent, lastDescent)
+		params.Series = append([]Series{
+			{Points: ascent, Color: color.RGBA{0xd0, 0x30, 0x20, 0xff}, EndLabel: "+" + FormatAltitude(lastAscent, unitType)},
+			{Points: descent, Color: color.RGBA{0x20, 0x60, 0xd0, 0xff}, EndLabel: "-" + FormatAltitude(lastDescent, unitType)},
+		}, params.Series...)
+	}
+	cs.prepareXAxis(&params, xc)
+	cs.prepareElevationAxis(&params.YAxis, 0, max, unitType)
+	return cs.chart(c, params, output)
+}
+
+// prepareForSteepness reduces and smooths the track before computing elevation angles
+func prepareForSteepness(g *gpx.GPX) {
+	cloneTracks(g)
 	g.ReduceTrackPoints(1000, 50)
 	g.SmoothVertical()
 	g.SmoothVertical()
 	g.SmoothVertical()
 	g.SmoothVertical()
-	var points []Point
-	var d float64
+}
+
+// elevationAngle returns the elevation angle (in degrees) at the point
+func elevationAngle(segment gpx.GPXTrackSegment, n int) float64 {
+	if 0 < n && n < len(segment.Points)-1 {
+		prevPt := segment.Points[n-1]
+		nextPt := segment.Points[n+1]
+		if prevPt.Elevation.NotNull() && nextPt.Elevation.NotNull() {
+			return gpx.ElevationAngle(prevPt.Point, nextPt.Point, false)
+		}
+	}
+	return 0
+}
+
+func (cs ChartService) steepnessChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return params, err
+	}
+	reduced := g
+	prepareForSteepness(&reduced)
+	line := newChartLine(params)
+	xc := newXAxisCounter(params, g)
+	xs := reducedPointsX(xc, g, reduced)
+	for trackNo, track := range reduced.Tracks {
+		for segmentNo, segment := range track.Segments {
+			for n := range segment.Points {
+				if x := xs[trackNo][segmentNo][n]; !math.IsNaN(x) {
+					line.add(Point{x, elevationAngle(segment, n)}, trackNo, segmentNo)
+				}
+			}
+		}
+	}
+
+	var (
+		sumFrom0 float64
+		count    int
+	)
+	for _, pt := range line.points {
+		if !isLineBreak(pt) {
+			sumFrom0 += math.Abs(pt.Y)
+			count++
+		}
+	}
+	max := 4 * sumFrom0 / float64(count)
+
+	params.MinY, params.MaxY = -max, max
+	params.prependSeries(Series{Points: line.points, Fill: true})
+	params.Spans = append(params.Spans, line.gaps...)
+	cs.prepareXAxis(&params, xc)
+	cs.prepareSteepnesAxis(&params.YAxis, max)
+	return params, nil
+}
+
+func (cs ChartService) SteepnessChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.steepnessChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+var DefaultGradeBuckets = []float64{-10, -5, 0, 5, 10, 15}
+
+// GradeColors are the colors for the elevation chart colored by grade, Colors[n] is
+// used for grades (in percent) from Edges[n-1] to (but not including) Edges[n], so
+// len(Colors) must be len(Edges)+1
+type GradeColors struct {
+	Edges  []float64
+	Colors []color.RGBA
+}
+
+var DefaultGradeColors = GradeColors{
+	Edges: []float64{3, 6, 9, 12},
+	Colors: []color.RGBA{
+		{0x30, 0xa0, 0x30, 0xff},
+		{0xf0, 0xd0, 0x20, 0xff},
+		{0xf0, 0x90, 0x20, 0xff},
+		{0xe0, 0x50, 0x20, 0xff},
+		{0xc0, 0x10, 0x10, 0xff},
+	},
+}
+
+func (gc GradeColors) validate() error {
+	if len(gc.Colors) != len(gc.Edges)+1 {
+		return fmt.Errorf("invalid grade colors: %d colors for %d edges", len(gc.Colors), len(gc.Edges))
+	}
+	if !sort.Float64sAreSorted(gc.Edges) {
+		return errors.New("invalid grade colors: edges not sorted")
+	}
+	return nil
+}
+
+func (gc GradeColors) color(grade float64) color.RGBA {
+	return gc.Colors[bucketIndex(gc.Edges, grade)]
+}
+
+// legend labels the colors by their [lower, upper) grade ranges (as in color, the
+// default font has no ≥ glyph).
+func (gc GradeColors) legend() []LegendItem {
+	var res []LegendItem
+	for n, c := range gc.Colors {
+		var label string
+		switch {
+		case len(gc.Edges) == 0:
+			label = "grade"
+		case n == 0:
+			label = fmt.Sprintf("<%s%%", FormatFloat(gc.Edges[0], 1))
+		case n == len(gc.Edges):
+			label = fmt.Sprintf(">=%s%%", FormatFloat(gc.Edges[n-1], 1))
+		default:
+			label = fmt.Sprintf("%s to <%s%%", FormatFloat(gc.Edges[n-1], 1), FormatFloat(gc.Edges[n], 1))
+		}
+		res = append(res, LegendItem{Color: c, Label: label})
+	}
+	return res
+}
+
+// pointGrades returns the grade (in percent) for every point, computed in the
+// same way as in SteepnessChart. The reduced track points are a subset of the
+// original points, points between them get the grade of the previous one.
+func pointGrades(g gpx.GPX) [][][]float64 {
+	reduced := g
+	prepareForSteepness(&reduced)
+	res := make([][][]float64, len(g.Tracks))
+	for trackNo, track := range g.Tracks {
+		res[trackNo] = make([][]float64, len(track.Segments))
+		for segmentNo, segment := range track.Segments {
+			reducedSegment := reduced.Tracks[trackNo].Segments[segmentNo]
+			grades := make([]float64, len(segment.Points))
+			var grade float64
+			j := 0
+			for n, pt := range segment.Points {
+				if j < len(reducedSegment.Points) && isReducedPoint(pt, reducedSegment.Points[j]) {
+					grade = 100 * math.Tan(elevationAngle(reducedSegment, j)*math.Pi/180)
+					j++
+				}
+				grades[n] = grade
+			}
+			res[trackNo][segmentNo] = grades
+		}
+	}
+	return res
+}
+
+// bucketIndex returns n for edges[n-1] <= value < edges[n] (edges must be sorted)
+func bucketIndex(edges []float64, value float64) int {
+	return sort.Search(len(edges), func(i int) bool { return edges[i] > value })
+}
+
+// gradeHistogram returns distance (or time, depending on XAxisMode) for every grade bucket,
+// len(result) == len(edges)+1
+func (cs ChartService) gradeHistogram(params ChartParams, g gpx.GPX, edges []float64) []float64 {
+	prepareForSteepness(&g)
+	res := make([]float64, len(edges)+1)
+	xc := newXAxisCounter(params, g)
 	for _, track := range g.Tracks {
 		for _, segment := range track.Segments {
+			var prevX float64
+			hasPrev := false
 			for n, pt := range segment.Points {
-				if n > 0 {
-					d += pt.Distance2D(&segment.Points[n-1])
+				x, ok := xc.next(pt, n == 0)
+				if !ok {
+					continue
 				}
-				angle := 0.0
-				if 0 < n && n < len(segment.Points)-1 {
-					prevPt := segment.Points[n-1]
-					nextPt := segment.Points[n+1]
-					if prevPt.Elevation.NotNull() && nextPt.Elevation.NotNull() {
-						angle = gpx.ElevationAngle(prevPt.Point, nextPt.Point, false)
-					}
+				if hasPrev {
+					grade := 100 * math.Tan(elevationAngle(segment, n)*math.Pi/180)
+					res[bucketIndex(edges, grade)] += x - prevX
 				}
-				points = append(points, Point{d, angle})
+				prevX, hasPrev = x, true
 			}
 		}
 	}
+	return res
+}
 
-	sumFrom0 := 0.0
-	for _, pt := range points {
-		sumFrom0 += math.Abs(pt.Y)
+func gradeBucketColor(from, to float64) color.RGBA {
+	grade := (from + to) / 2
+	if math.IsInf(from, -1) {
+		grade = to
+	} else if math.IsInf(to, 1) {
+		grade = from
+	}
+	if grade < 0 {
+		return color.RGBA{0x40, 0x80, 0xd0, 0xff}
 	}
-	max := 4 * sumFrom0 / float64(len(points))
+	k := math.Min(1, grade/15)
+	return color.RGBA{uint8(0x40 + 0xb0*k), uint8(0xb0 - 0x80*k), 0x30, 0xff}
+}
 
-	params.MinY, params.MaxY = -max, max
-	params.Points = points
-	cs.prepareLengthAxis(&params.XAxis, d, params.UnitTypeOrMetric())
-	cs.prepareSteepnesAxis(&params.YAxis, max)
+func (cs ChartService) prepareGradeBucketsAxis(axis *Axis, edges []float64) {
+	axis.Formatter = func(f float64) string {
+		n := int(math.Round(f)) - 1
+		if 0 <= n && n < len(edges) {
+			return FormatFloat(edges[n], 1) + "%"
+		}
+		return ""
+	}
+	if axis.Grid == 0 {
+		axis.Grid = 1
+	}
+	if axis.Labels == 0 {
+		axis.Labels = 1
+	}
+}
+
+// GradeHistogramChart shows the distance (or time, for time based XAxisMode) spent in every grade bucket
+func (cs ChartService) GradeHistogramChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return nil, err
+	}
+	edges := params.GradeBuckets
+	if len(edges) == 0 {
+		edges = DefaultGradeBuckets
+	}
+	edges = append([]float64(nil), edges...)
+	sort.Float64s(edges)
+
+	histogram := cs.gradeHistogram(params, g, edges)
+	var total, max float64
+	for _, v := range histogram {
+		total += v
+		max = math.Max(max, v)
+	}
+
+	params.Series = nil
+	params.Bars = nil
+	if total > 0 {
+		for n, v := range histogram {
+			from, to := math.Inf(-1), math.Inf(1)
+			if n > 0 {
+				from = edges[n-1]
+			}
+			if n < len(edges) {
+				to = edges[n]
+			}
+			params.Bars = append(params.Bars, Bar{
+				MinX:  float64(n) + 0.1,
+				MaxX:  float64(n) + 0.9,
+				MinY:  0,
+				MaxY:  v,
+				Color: gradeBucketColor(from, to),
+				Label: fmt.Sprintf("%d%%", int(math.Round(100*v/total))),
+			})
+		}
+	}
+
+	// Chart padding is in chart units, which doesn't make sense for buckets:
+	params.ChartPadding.Left, params.ChartPadding.Right = 0, 0
+	params.MinX, params.MaxX = 0, float64(len(histogram))
+	// Space for labels above bars:
+	params.MinY, params.MaxY = 0, 1.15*max
+	cs.prepareGradeBucketsAxis(&params.XAxis, edges)
+	switch params.XAxisModeOrDistance() {
+	case XAxisDistance:
+		cs.prepareLengthAxis(&params.YAxis, max, params.UnitTypeOrMetric())
+	default:
+		cs.prepareDurationAxis(&params.YAxis, max)
+	}
 	return cs.chart(c, params, output)
 }
 
-func (cs ChartService) ElevationChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+func (cs ChartService) elevationChartParams(params ChartParams, g gpx.GPX) (ChartParams, error) {
+	params, g, err := params.selectSource(g)
+	if err != nil {
+		return params, err
+	}
 	var (
 		minElevation = 1000.0
 		maxElevation = 0.0
-		points       []Point
-		d            float64
+		grades       [][][]float64
+		gradeColors  GradeColors
+		fillColors   []color.RGBA
 	)
-	for _, track := range g.Tracks {
-		for _, segment := range track.Segments {
+	if params.ColorByGrade {
+		gradeColors = params.GradeColorsOrDefault()
+		if err := gradeColors.validate(); err != nil {
+			return params, err
+		}
+		grades = pointGrades(g)
+		params.Legend = append(params.Legend, gradeColors.legend()...)
+	}
+	line := newChartLine(params)
+	xc := newXAxisCounter(params, g)
+	for trackNo, track := range g.Tracks {
+		for segmentNo, segment := range track.Segments {
 			for n, pt := range segment.Points {
-				if n > 0 {
-					d += pt.Distance2D(&segment.Points[n-1])
+				x, ok := xc.next(pt, n == 0)
+				if !ok {
+					continue
 				}
 				ele := pt.Elevation.Value()
-				points = append(points, Point{d, ele})
+				line.add(Point{x, ele}, trackNo, segmentNo)
+				// Also for the line break (if added):
+				for params.ColorByGrade && len(fillColors) < len(line.points) {
+					fillColors = append(fillColors, gradeColors.color(grades[trackNo][segmentNo][n]))
+				}
 
 				if ele < minElevation {
 					minElevation = ele
@@ -701,8 +2412,77 @@ func (cs ChartService) ElevationChart(c context.Context, params ChartParams, g g
 		}
 	}
 
-	params.Points = points
-	cs.prepareLengthAxis(&params.XAxis, d, params.UnitTypeOrMetric())
+	points := line.points
+	params.prependSeries(Series{Points: points, Fill: true, FillColors: fillColors})
+	params.Spans = append(params.Spans, line.gaps...)
+	if params.Climbs {
+		climbs, err := cs.Climbs(params, g)
+		if err != nil {
+			return params, err
+		}
+		params.Spans = append(params.Spans, climbSpans(climbs, params.UnitTypeOrMetric())...)
+	}
+	if params.Waypoints {
+		params.Markers = append(params.Markers, cs.waypointMarkers(params, g)...)
+	}
+	if params.MinMaxMarkers {
+		params.Markers = append(params.Markers, minMaxMarkers(points, true, func(f float64) string { return FormatAltitude(f, params.UnitTypeOrMetric()) })...)
+	}
+	params.statsBox = cs.statsBox(params, g)
+	cs.prepareXAxis(&params, xc)
 	cs.prepareElevationAxis(&params.YAxis, minElevation, maxElevation, params.UnitTypeOrMetric())
+	return params, nil
+}
+
+func (cs ChartService) ElevationChart(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error) {
+	params, err := cs.elevationChartParams(params, g)
+	if err != nil {
+		return nil, err
+	}
+	return cs.chart(c, params, output)
+}
+
+func (cs ChartService) extensionChartParams(params ChartParams, g ExtendedGPX, field string) (ChartParams, error) {
+	params, g, err := params.selectExtendedSource(g)
+	if err != nil {
+		return params, err
+	}
+	var minV, maxV float64
+	line := newChartLine(params)
+	xc := newXAxisCounter(params, g.GPX)
+	for trackNo, track := range g.Tracks {
+		for segmentNo, segment := range track.Segments {
+			for n, pt := range segment.Points {
+				x, ok := xc.next(pt, n == 0)
+				if !ok {
+					continue
+				}
+				v, found := g.Extensions(trackNo, segmentNo, n).Get(field)
+				if !found {
+					continue
+				}
+				if len(line.points) == 0 || v < minV {
+					minV = v
+				}
+				if len(line.points) == 0 || v > maxV {
+					maxV = v
+				}
+				line.add(Point{x, v}, trackNo, segmentNo)
+			}
+		}
+	}
+
+	params.prependSeries(Series{Points: line.points, Fill: true})
+	params.Spans = append(params.Spans, line.gaps...)
+	cs.prepareXAxis(&params, xc)
+	cs.prepareExtensionAxis(&params.YAxis, field, minV, maxV)
+	return params, nil
+}
+
+func (cs ChartService) ExtensionChart(c context.Context, params ChartParams, g ExtendedGPX, field string, output OutputExtension) ([]byte, error) {
+	params, err := cs.extensionChartParams(params, g, field)
+	if err != nil {
+		return nil, err
+	}
 	return cs.chart(c, params, output)
 }
@@ -3,8 +3,13 @@ package gpxcharts
 import (
 	"context"
 	"fmt"
+	"image/color"
+	"io/ioutil"
+	"math"
 	"os"
+	"path"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/tkrajina/gpxgo/gpx"
@@ -32,6 +37,10 @@ func TestChart(t *testing.T) {
 		chartService.ElevationChart,
 		chartService.SpeedChart,
 		chartService.SteepnessChart,
+		chartService.PaceChart,
+		chartService.VerticalSpeedChart,
+		chartService.AscentDescentChart,
+		chartService.GradeHistogramChart,
 	}
 	unitTypes := []UnitType{
 		UnitTypeMetric,
@@ -93,3 +102,502 @@ func testChart(t *testing.T, fileName string, chartFunc func(c context.Context,
 
 	return byts
 }
+
+// smokeChart renders a chart in the given output format
+type smokeChart func(output OutputExtension) ([]byte, error)
+
+// TestRenderSmoke renders every chart type and feature (PNG and SVG) into ../tmp. It only checks that
+// charts are drawn without errors, the chart data is checked in the tests of every feature.
+func TestRenderSmoke(t *testing.T) {
+	t.Parallel()
+
+	c := context.Background()
+	garmin, err := gpx.ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	garminExt, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	zbevnica, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	parenzana, err := gpx.ParseFile("../test_files/parenzana.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	empty, err := gpx.ParseFile("../test_files/empty.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	luxi, err := ioutil.ReadFile("../fonts/luxisr.ttf")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	fontService, err := NewChartServiceWithFont(luxi)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	axes := ChartParams{Width: 900, Height: 250, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}}
+	// with returns the params with axes changed by fn
+	with := func(fn func(params *ChartParams)) ChartParams {
+		params := axes
+		fn(&params)
+		return params
+	}
+	mapParams := ChartParams{Width: 300, Height: 200, ChartMargin: Padding{Left: 10, Right: 10, Top: 10, Bottom: 10}, ScaleBar: true, NorthArrow: true}
+
+	charts := map[string]smokeChart{
+		"stats_elevation": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, with(func(params *ChartParams) { params.Stats, params.MinMaxMarkers = AllStatsFields(), true }), *garmin, output)
+		},
+		"stats_speed": func(output OutputExtension) ([]byte, error) {
+			params := with(func(params *ChartParams) {
+				params.Stats, params.MinMaxMarkers, params.StatsCorner = AllStatsFields(), true, CornerBottomRight
+			})
+			return chartService.SpeedChart(c, params, *garmin, output)
+		},
+		"extension_hr": func(output OutputExtension) ([]byte, error) {
+			return chartService.ExtensionChart(c, axes, *garminExt, "gpxtpx:hr", output)
+		},
+		"zones": func(output OutputExtension) ([]byte, error) {
+			zones := Zones{Metric: ZoneMetricExtension, Field: "gpxtpx:hr", Boundaries: []float64{100, 120, 140, 155}}
+			return chartService.ZoneChart(c, ChartParams{Width: 600, Height: 200}, *garminExt, zones, output)
+		},
+		"map_zbevnica": func(output OutputExtension) ([]byte, error) {
+			return chartService.MapChart(c, mapParams, *zbevnica, output)
+		},
+		"map_parenzana": func(output OutputExtension) ([]byte, error) {
+			return chartService.MapChart(c, mapParams, *parenzana, output)
+		},
+		"map_empty": func(output OutputExtension) ([]byte, error) {
+			return chartService.MapChart(c, mapParams, *empty, output)
+		},
+		"colored_by_grade": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, ChartParams{Width: 900, Height: 200, ColorByGrade: true}, *zbevnica, output)
+		},
+		"panels": func(output OutputExtension) ([]byte, error) {
+			types := []ChartType{ChartTypeElevation, ChartTypeSpeed, ChartTypeSteepness, ExtensionChartType("gpxtpx:hr")}
+			return chartService.PanelChart(c, with(func(params *ChartParams) { params.Height = 400 }), *garminExt, types, output)
+		},
+		"overlay_elevation": func(output OutputExtension) ([]byte, error) {
+			return chartService.OverlayChart(c, ChartParams{Width: 900, Height: 200}, ChartTypeElevation, []gpx.GPX{*zbevnica, *parenzana, *empty}, output)
+		},
+		"overlay_steepness": func(output OutputExtension) ([]byte, error) {
+			return chartService.OverlayChart(c, ChartParams{Width: 900, Height: 200}, ChartTypeSteepness, []gpx.GPX{*zbevnica, *parenzana, *empty}, output)
+		},
+		"time_gap": func(output OutputExtension) ([]byte, error) {
+			return chartService.TimeGapChart(c, ChartParams{Width: 900, Height: 200}, *parenzana, []gpx.GPX{fasterCopy(*parenzana, 1.1)}, output)
+		},
+		"time_gaps": func(output OutputExtension) ([]byte, error) {
+			return chartService.TimeGapChart(c, ChartParams{Width: 900, Height: 200}, *parenzana, []gpx.GPX{fasterCopy(*parenzana, 1.1), fasterCopy(*parenzana, 0.95)}, output)
+		},
+		"waypoints": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, with(func(params *ChartParams) { params.Waypoints, params.MaxWaypointDistance = true, 200 }), *zbevnica, output)
+		},
+		"route_elevation": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, ChartParams{Width: 900, Height: 200, Source: SourceRoute}, routeOnly(*zbevnica), output)
+		},
+		"route_steepness": func(output OutputExtension) ([]byte, error) {
+			return chartService.SteepnessChart(c, ChartParams{Width: 900, Height: 200, Source: SourceRoute, SourceNo: 1}, routeOnly(*zbevnica), output)
+		},
+		"climbs": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, with(func(params *ChartParams) { params.Climbs = true }), *zbevnica, output)
+		},
+		"gaps_hatch": func(output OutputExtension) ([]byte, error) {
+			params := with(func(params *ChartParams) {
+				params.GapPolicy, params.GapDistance, params.ColorByGrade = GapHatch, true, true
+			})
+			return chartService.ElevationChart(c, params, twoSegmentsGPX(), output)
+		},
+		"gaps_break": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, with(func(params *ChartParams) { params.GapPolicy, params.GapDistance = GapBreak, true }), twoSegmentsGPX(), output)
+		},
+		"stops_speed": func(output OutputExtension) ([]byte, error) {
+			params := with(func(params *ChartParams) {
+				params.XAxisMode, params.Stops, params.StopSpeed, params.StopDuration = XAxisElapsedTime, true, 1, 10*time.Second
+			})
+			return chartService.SpeedChart(c, params, *garmin, output)
+		},
+		"splits_pace": func(output OutputExtension) ([]byte, error) {
+			return chartService.SplitsChart(c, axes, *garmin, output)
+		},
+		"splits_time": func(output OutputExtension) ([]byte, error) {
+			return chartService.SplitsChart(c, with(func(params *ChartParams) { params.SplitMetric = SplitTime }), *garmin, output)
+		},
+		"sparkline": func(output OutputExtension) ([]byte, error) {
+			params := ChartParams{Width: SparklineWidth, Height: SparklineHeight, Sparkline: true, SparklineMinMax: true, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}}
+			return chartService.ElevationChart(c, params, *zbevnica, output)
+		},
+		"custom_chart": func(output OutputExtension) ([]byte, error) {
+			params := ChartParams{
+				Width:       600,
+				Height:      200,
+				MinX:        0,
+				MaxX:        10,
+				MinY:        -5,
+				MaxY:        10,
+				MinY2:       0,
+				MaxY2:       100,
+				XAxis:       Axis{Show: true, Labels: 2},
+				YAxis:       Axis{Show: true, Labels: 5},
+				Y2Axis:      Axis{Show: true, Labels: 25},
+				ChartMargin: Padding{Left: 40, Right: 40, Bottom: 20},
+				Series: []Series{
+					{Points: []Point{{0, 2}, {3, 8}, {5, -4}, {10, 6}}, Fill: true, NegativeFillColor: color.RGBA{0xc0, 0x30, 0x30, 0xff}, Label: "filled"},
+					{Points: []Point{{0, 10}, {5, 90}, {10, 40}}, Color: color.RGBA{0x20, 0xa0, 0x20, 0xff}, LineWidth: 3, Y2: true, Label: "y2"},
+					{Points: []Point{{0, 0}, {10, 0}}, Color: color.RGBA{0x60, 0x60, 0x60, 0xff}, Dash: []float64{2, 2}},
+				},
+			}
+			return chartService.Chart(c, params, output)
+		},
+		"gradient": func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, with(func(params *ChartParams) {
+				params.TransparentBackground = true
+				params.FillGradient = &Gradient{Top: color.RGBA{0xd0, 0x40, 0x20, 0xd0}, Bottom: color.RGBA{0x10, 0x04, 0x02, 0x10}}
+			}), *zbevnica, output)
+		},
+		"fonts": func(output OutputExtension) ([]byte, error) {
+			return fontService.ElevationChart(c, with(func(params *ChartParams) {
+				params.Title = "Zbevnica"
+				params.TitleFont = Font{Size: 16}
+				params.AxisFont = Font{Size: 10, Style: FontItalic}
+				params.AnnotationFont = Font{Size: 12}
+				params.MinMaxMarkers = true
+				params.YAxis.FontSize = 6
+			}), *zbevnica, output)
+		},
+	}
+
+	chartFuncs := []func(c context.Context, params ChartParams, g gpx.GPX, output OutputExtension) ([]byte, error){
+		chartService.ElevationChart,
+		chartService.SpeedChart,
+		chartService.SteepnessChart,
+		chartService.PaceChart,
+		chartService.VerticalSpeedChart,
+		chartService.AscentDescentChart,
+		chartService.GradeHistogramChart,
+	}
+	for _, fn := range []string{"../test_files/parenzana.gpx", "../test_files/garmin.gpx", "../test_files/track.gpx"} {
+		for _, mode := range AllXAxisModes() {
+			for m, f := range chartFuncs {
+				fn, f, params := fn, f, ChartParams{Width: 900, Height: 200, XAxisMode: mode, TimeZone: time.FixedZone("CET", 3600)}
+				charts[fmt.Sprintf("x_%s_%d_%s", mode, m, path.Base(fn))] = func(output OutputExtension) ([]byte, error) {
+					g, err := gpx.ParseFile(fn)
+					if err != nil {
+						return nil, err
+					}
+					return f(c, params, *g, output)
+				}
+			}
+		}
+	}
+	for _, typ := range append(AllChartTypes(), ExtensionChartType("gpxtpx:hr")) {
+		typ := typ
+		charts["dual_axis_"+typ.Name()] = func(output OutputExtension) ([]byte, error) {
+			return chartService.DualAxisChart(c, axes, *garminExt, typ, output)
+		}
+	}
+
+	for _, name := range AllThemeNames() {
+		theme, _ := BuiltinTheme(name)
+		params := with(func(params *ChartParams) {
+			params.Theme = theme
+			params.MinMaxMarkers = true
+			params.Stats = []StatsField{StatsDistance, StatsAscent}
+			params.XAxis.Grid = 500
+			params.YAxis.Grid = 50
+		})
+		charts["theme_"+string(name)] = func(output OutputExtension) ([]byte, error) {
+			return chartService.ElevationChart(c, params, *zbevnica, output)
+		}
+	}
+	for name, chart := range charts {
+		for _, output := range []OutputExtension{OutputPNG, OutputSVG} {
+			byts, err := chart(output)
+			if assert.Nil(t, err, name) && assert.NotEmpty(t, byts, name) {
+				assert.Nil(t, ioutil.WriteFile("../tmp/tmp_smoke_"+name+string(output), byts, 0644))
+			}
+		}
+	}
+}
+
+func TestXAxisModes(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	lastX := map[XAxisMode]float64{}
+	for _, mode := range AllXAxisModes() {
+		params, err := chartService.elevationChartParams(ChartParams{XAxisMode: mode, TimeZone: time.FixedZone("CET", 3600)}, *g)
+		assert.Nil(t, err)
+		points := params.mainPoints()
+		for n := 1; n < len(points); n++ {
+			assert.True(t, points[n].X >= points[n-1].X, mode)
+		}
+		lastX[mode] = points[len(points)-1].X
+		if mode == XAxisClockTime {
+			assert.Equal(t, float64(g.TimeBounds().StartTime.Unix()+3600), points[0].X)
+		} else {
+			assert.Equal(t, 0., points[0].X, mode)
+		}
+	}
+	assert.InDelta(t, g.Length2D(), lastX[XAxisDistance], 1)
+	assert.InDelta(t, g.TimeBounds().EndTime.Sub(g.TimeBounds().StartTime).Seconds(), lastX[XAxisElapsedTime], 1)
+	assert.True(t, lastX[XAxisMovingTime] < lastX[XAxisElapsedTime])
+	assert.True(t, lastX[XAxisMovingTime] > lastX[XAxisElapsedTime]/2)
+}
+
+func TestClockAxisDaySeparators(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2020, 9, 12, 20, 0, 0, 0, time.UTC)
+	var axis Axis
+	chartService.prepareClockAxis(&axis, float64(start.Unix()), float64(start.Add(30*time.Hour).Unix()))
+	assert.Equal(t, []AxisSeparator{
+		{Value: float64(time.Date(2020, 9, 13, 0, 0, 0, 0, time.UTC).Unix()), Label: "Sun 13 Sep"},
+		{Value: float64(time.Date(2020, 9, 14, 0, 0, 0, 0, time.UTC).Unix()), Label: "Mon 14 Sep"},
+	}, axis.Separators)
+	assert.Equal(t, 2*3600.0, axis.Grid)
+	assert.Equal(t, 6*3600.0, axis.Labels)
+	assert.Equal(t, "20:00", axis.Formatter(float64(start.Unix())))
+}
+
+func TestClockAxisDST(t *testing.T) {
+	t.Parallel()
+
+	tz, err := time.LoadLocation("Europe/Ljubljana")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	// CET on the 28th, CEST (after the DST change) on the 29th:
+	xc := newXAxisCounter(ChartParams{XAxisMode: XAxisClockTime, TimeZone: tz}, gpx.GPX{})
+	for _, expected := range []struct {
+		time  time.Time
+		label string
+	}{
+		{time.Date(2020, 3, 28, 12, 0, 0, 0, time.UTC), "13:00"},
+		{time.Date(2020, 3, 29, 0, 30, 0, 0, time.UTC), "01:30"},
+		{time.Date(2020, 3, 29, 12, 0, 0, 0, time.UTC), "14:00"},
+		{time.Date(2020, 3, 30, 12, 0, 0, 0, time.UTC), "14:00"},
+	} {
+		x, ok := xc.next(gpx.GPXPoint{Timestamp: expected.time}, false)
+		assert.True(t, ok)
+		assert.Equal(t, expected.label, FormatClock(x), expected.time.String())
+	}
+}
+
+func TestFormatPace(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "5:30/km", FormatPace(0.33, PaceUnitKm))
+	assert.Equal(t, "5:30/km", FormatPace(0.33, ""))
+	assert.Equal(t, "8:51/mi", FormatPace(0.33, PaceUnitMile))
+	assert.Equal(t, "0:33/100m", FormatPace(0.33, PaceUnit100m))
+	assert.Equal(t, "1:00/km", FormatPace(0.05999, PaceUnitKm))
+	for _, pace := range []float64{0, -1, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		assert.Equal(t, "n/a", FormatPace(pace, PaceUnitKm))
+	}
+}
+
+func TestPaceChartMaxPace(t *testing.T) {
+	t.Parallel()
+
+	// 5:00/km, with a (near zero speed) 30min stop in the middle:
+	g := pacedGPX(300, 300, 300, 300)
+	points := g.Tracks[0].Segments[0].Points
+	for n := 200; n < len(points); n++ {
+		points[n].Timestamp = points[n].Timestamp.Add(30 * time.Minute)
+	}
+
+	params, err := chartService.paceChartParams(ChartParams{}, g)
+	assert.Nil(t, err)
+	var maxPace float64
+	for _, pt := range params.Series[0].Points {
+		assert.True(t, pt.Y > 0)
+		maxPace = math.Max(maxPace, pt.Y)
+	}
+	assert.InDelta(t, maxPaceToMedian*300, maxPace, 0.01)
+	assert.InDelta(t, 300, params.Series[0].Points[50].Y, 1)
+	assert.True(t, params.YAxis.Inverted)
+}
+
+// climbGPX returns a track with a point every minute, climbing (at the given vertical speeds, in
+// meters per hour) for every 30 minutes
+func climbGPX(verticalSpeeds ...float64) gpx.GPX {
+	var segment gpx.GPXTrackSegment
+	start := time.Date(2020, 5, 1, 8, 0, 0, 0, time.UTC)
+	ele := 100.
+	for n := 0; n <= len(verticalSpeeds)*30; n++ {
+		if n > 0 {
+			ele += verticalSpeeds[(n-1)/30] / 60
+		}
+		segment.Points = append(segment.Points, gpx.GPXPoint{
+			Point:     gpx.Point{Latitude: 0, Longitude: float64(n) * 100 / oneDegree, Elevation: *gpx.NewNullableFloat64(ele)},
+			Timestamp: start.Add(time.Duration(n) * time.Minute),
+		})
+	}
+	return gpx.GPX{Tracks: []gpx.GPXTrack{{Segments: []gpx.GPXTrackSegment{segment}}}}
+}
+
+func TestVerticalSpeed(t *testing.T) {
+	t.Parallel()
+
+	params, err := chartService.verticalSpeedChartParams(ChartParams{}, climbGPX(600, 600))
+	assert.Nil(t, err)
+	points := params.Series[0].Points
+	assert.Equal(t, 61, len(points))
+	for _, pt := range points {
+		assert.InDelta(t, 600, pt.Y, 0.01)
+	}
+
+	// 30min @ 1200m/h, then flat, the value at 25min is averaged over ±1min (default) and ±10min:
+	g := climbGPX(1200, 0)
+	params, err = chartService.verticalSpeedChartParams(ChartParams{}, g)
+	assert.Nil(t, err)
+	points = params.Series[0].Points
+	assert.InDelta(t, 1200, points[25].Y, 0.01)
+	assert.InDelta(t, 600, points[30].Y, 0.01)
+	assert.InDelta(t, 0, points[35].Y, 0.01)
+	params, err = chartService.verticalSpeedChartParams(ChartParams{VerticalSpeedWindow: 20 * time.Minute}, g)
+	assert.Nil(t, err)
+	points = params.Series[0].Points
+	assert.InDelta(t, 900, points[25].Y, 0.01)
+	assert.InDelta(t, 600, points[30].Y, 0.01)
+	assert.InDelta(t, 300, points[35].Y, 0.01)
+}
+
+func TestCumulativeAscentDescent(t *testing.T) {
+	t.Parallel()
+
+	for _, fn := range []string{"../test_files/parenzana.gpx", "../test_files/garmin.gpx", "../test_files/zbevnica.gpx"} {
+		g, err := gpx.ParseFile(fn)
+		if !assert.Nil(t, err) {
+			t.FailNow()
+		}
+		updo := g.UphillDownhill()
+
+		// Without hysteresis:
+		ascentLine, descentLine, _ := chartService.cumulativeAscentDescent(ChartParams{AscentThreshold: -1}, *g)
+		ascent, descent := ascentLine.points, descentLine.points
+		assert.InDelta(t, updo.Uphill, ascent[len(ascent)-1].Y, 0.001, fn)
+		assert.InDelta(t, updo.Downhill, descent[len(descent)-1].Y, 0.001, fn)
+
+		var segments int
+		for _, track := range g.Tracks {
+			segments += len(track.Segments)
+		}
+		for _, threshold := range []float64{0, 5} {
+			params := ChartParams{AscentThreshold: threshold}
+			ascentLine, descentLine, _ := chartService.cumulativeAscentDescent(params, *g)
+			ascent, descent := ascentLine.points, descentLine.points
+			lastAscent, lastDescent := ascent[len(ascent)-1].Y, descent[len(descent)-1].Y
+			assert.True(t, lastAscent < updo.Uphill, fn)
+			assert.True(t, lastDescent < updo.Downhill, fn)
+			assert.InDelta(t, updo.Uphill-updo.Downhill, lastAscent-lastDescent, params.AscentThresholdOrDefault()*float64(segments), fn)
+			for n := 1; n < len(ascent); n++ {
+				assert.True(t, ascent[n].Y >= ascent[n-1].Y)
+				assert.True(t, descent[n].Y >= descent[n-1].Y)
+			}
+		}
+	}
+
+	// Flat, with elevation noise:
+	g := profileGPX(0, 0)
+	for n := range g.Tracks[0].Segments[0].Points {
+		g.Tracks[0].Segments[0].Points[n].Elevation = *gpx.NewNullableFloat64(100 + float64(n%2)*4)
+	}
+	assert.True(t, g.UphillDownhill().Uphill > 50)
+	ascentLine, _, _ := chartService.cumulativeAscentDescent(ChartParams{}, g)
+	assert.Equal(t, 0.0, ascentLine.points[len(ascentLine.points)-1].Y)
+}
+
+func TestGradeHistogram(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/parenzana.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	histogram := chartService.gradeHistogram(ChartParams{}, *g, DefaultGradeBuckets)
+	assert.Equal(t, len(DefaultGradeBuckets)+1, len(histogram))
+	var sum float64
+	for _, v := range histogram {
+		assert.True(t, v >= 0)
+		sum += v
+	}
+	reduced := *g
+	prepareForSteepness(&reduced)
+	assert.InEpsilon(t, reduced.Length2D(), sum, 0.001)
+
+	histogram = chartService.gradeHistogram(ChartParams{XAxisMode: XAxisElapsedTime}, *g, []float64{0})
+	assert.Equal(t, 2, len(histogram))
+	assert.InDelta(t, reduced.Duration(), histogram[0]+histogram[1], 1)
+
+	// Flat, bucket edges are included in the upper bucket:
+	flat := profileGPX(0, 0, 0)
+	histogram = chartService.gradeHistogram(ChartParams{}, flat, DefaultGradeBuckets)
+	assert.Equal(t, []float64{0, 0, 0, histogram[3], 0, 0, 0}, histogram)
+	assert.InDelta(t, 3000, histogram[3], 50)
+	histogram = chartService.gradeHistogram(ChartParams{}, flat, []float64{-1, 0, 1})
+	assert.Equal(t, []float64{0, 0, histogram[2], 0}, histogram)
+	assert.True(t, histogram[2] > 0)
+
+	for value, expected := range map[float64]int{-20: 0, -10: 1, -5: 2, -0.1: 2, 0: 3, 4.9: 3, 5: 4, 15: 6, 20: 6} {
+		assert.Equal(t, expected, bucketIndex(DefaultGradeBuckets, value), value)
+	}
+}
+
+func TestElevationChartColoredByGrade(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+
+	grades := pointGrades(*g)
+	assert.Equal(t, len(g.Tracks), len(grades))
+	var max float64
+	for trackNo, track := range g.Tracks {
+		for segmentNo, segment := range track.Segments {
+			assert.Equal(t, len(segment.Points), len(grades[trackNo][segmentNo]))
+			for _, grade := range grades[trackNo][segmentNo] {
+				max = math.Max(max, grade)
+			}
+		}
+	}
+	assert.True(t, max > 12, "max=%f", max)
+
+	assert.Equal(t, DefaultGradeColors.Colors[0], DefaultGradeColors.color(-5))
+	assert.Equal(t, DefaultGradeColors.Colors[1], DefaultGradeColors.color(4))
+	assert.Equal(t, DefaultGradeColors.Colors[4], DefaultGradeColors.color(20))
+	// Edges are in the upper range, same as in the legend:
+	assert.Equal(t, DefaultGradeColors.Colors[0], DefaultGradeColors.color(2.99))
+	assert.Equal(t, DefaultGradeColors.Colors[1], DefaultGradeColors.color(3))
+	assert.Equal(t, DefaultGradeColors.Colors[2], DefaultGradeColors.color(6))
+	assert.Equal(t, DefaultGradeColors.Colors[4], DefaultGradeColors.color(12))
+	assert.Equal(t, "<3%", DefaultGradeColors.legend()[0].Label)
+	assert.Equal(t, "3 to <6%", DefaultGradeColors.legend()[1].Label)
+	assert.Equal(t, ">=12%", DefaultGradeColors.legend()[4].Label)
+
+	params, err := chartService.elevationChartParams(ChartParams{ColorByGrade: true}, *g)
+	assert.Nil(t, err)
+	series := params.Series[0]
+	if assert.Equal(t, len(series.Points), len(series.FillColors)) {
+		points := g.Tracks[0].Segments[0].Points
+		assert.Equal(t, DefaultGradeColors.color(grades[0][0][0]), series.FillColors[0])
+		assert.Equal(t, DefaultGradeColors.color(grades[0][0][len(points)-1]), series.FillColors[len(points)-1])
+	}
+	assert.Equal(t, DefaultGradeColors.legend(), params.Legend)
+
+	red, err := ParseHexColor("#c01010")
+	assert.Nil(t, err)
+	_, err = chartService.ElevationChart(context.Background(), ChartParams{Width: 900, Height: 200, ColorByGrade: true, GradeColors: GradeColors{Edges: []float64{5, 10}, Colors: []color.RGBA{red}}}, *g, OutputPNG)
+	assert.NotNil(t, err)
+}
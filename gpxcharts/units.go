@@ -62,6 +62,49 @@ func AllUnitTypes() []UnitType {
 	}
 }
 
+type PaceUnit string
+
+const (
+	PaceUnitKm           PaceUnit = "km"
+	PaceUnitMile         PaceUnit = "mi"
+	PaceUnit100m         PaceUnit = "100m"
+	PaceUnitNauticalMile PaceUnit = "NM"
+)
+
+func AllPaceUnits() []PaceUnit {
+	return []PaceUnit{
+		PaceUnitKm,
+		PaceUnitMile,
+		PaceUnit100m,
+		PaceUnitNauticalMile,
+	}
+}
+
+// Length in meters
+func (pu PaceUnit) Length() float64 {
+	switch pu {
+	case PaceUnitMile:
+		return ONE_MILE
+	case PaceUnit100m:
+		return 100
+	case PaceUnitNauticalMile:
+		return ONE_NAUTICAL_MILE
+	default:
+		return 1000
+	}
+}
+
+func (ut UnitType) PaceUnit() PaceUnit {
+	switch ut {
+	case UnitTypeImperial:
+		return PaceUnitMile
+	case UnitTypeNautical:
+		return PaceUnitNauticalMile
+	default:
+		return PaceUnitKm
+	}
+}
+
 var (
 	SPEED_MPS  = 1.
 	SPEED_KMH  = 1000. / math.Pow(60., 2)
@@ -6,11 +6,15 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
 	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/llgcode/draw2d/draw2dsvg"
+	"github.com/tkrajina/gpxgo/gpx"
 )
 
 func SVGToBytes(svg *draw2dsvg.Svg) ([]byte, error) {
@@ -29,6 +33,20 @@ func RGBAToBytes(m *image.RGBA) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// cloneTracks copies tracks and segments, so that they can be modified (for
+// example reduced or smoothed) without changing the original GPX
+func cloneTracks(g *gpx.GPX) {
+	tracks := make([]gpx.GPXTrack, len(g.Tracks))
+	for trackNo, track := range g.Tracks {
+		tracks[trackNo] = track
+		tracks[trackNo].Segments = make([]gpx.GPXTrackSegment, len(track.Segments))
+		for segmentNo, segment := range track.Segments {
+			tracks[trackNo].Segments[segmentNo].Points = append([]gpx.GPXPoint(nil), segment.Points...)
+		}
+	}
+	g.Tracks = tracks
+}
+
 func FormatSpeed(meters_per_seconds float64, unit_type UnitType, round bool) string {
 	if meters_per_seconds <= 0 {
 		return "n/a"
@@ -62,6 +80,18 @@ func FormatSpeed(meters_per_seconds float64, unit_type UnitType, round bool) str
 	return fmt.Sprintf("%.1f%s", speed, unit)
 }
 
+// FormatPace formats pace (in seconds per meter) as (for example) "5:30/km"
+func FormatPace(secondsPerMeter float64, unit PaceUnit) string {
+	if secondsPerMeter <= 0 || IsNanOrOnf(secondsPerMeter) {
+		return "n/a"
+	}
+	if len(unit) == 0 {
+		unit = PaceUnitKm
+	}
+	seconds := int(math.Round(secondsPerMeter * unit.Length()))
+	return fmt.Sprintf("%d:%02d/%s", seconds/60, seconds%60, unit)
+}
+
 func FormatLength(lengthM float64, ut UnitType) string {
 	if lengthM < 0 {
 		return "n/a"
@@ -118,6 +148,47 @@ func FormatFloat(f float64, digits int) string {
 	return strings.TrimRight(res, ".")
 }
 
+// FormatDuration formats seconds as (for example) "1h30", "2h", "45min" or "30s"
+func FormatDuration(seconds float64) string {
+	if seconds < 0 || IsNanOrOnf(seconds) {
+		return "n/a"
+	}
+	total := int(math.Round(seconds))
+	h, m, s := total/3600, (total%3600)/60, total%60
+	if h > 0 {
+		if m == 0 {
+			return fmt.Sprintf("%dh", h)
+		}
+		return fmt.Sprintf("%dh%02d", h, m)
+	}
+	if m > 0 {
+		if s == 0 {
+			return fmt.Sprintf("%dmin", m)
+		}
+		return fmt.Sprintf("%dmin%02ds", m, s)
+	}
+	return fmt.Sprintf("%ds", s)
+}
+
+// FormatClock formats seconds since (time zone shifted) epoch as "14:00"
+func FormatClock(seconds float64) string {
+	if IsNanOrOnf(seconds) {
+		return "n/a"
+	}
+	return time.Unix(int64(math.Round(seconds)), 0).UTC().Format("15:04")
+}
+
+// FormatVerticalSpeed formats vertical speed (in meters per hour)
+func FormatVerticalSpeed(metersPerHour float64, unitType UnitType) string {
+	if IsNanOrOnf(metersPerHour) {
+		return "n/a"
+	}
+	if unitType == UnitTypeImperial || unitType == UnitTypeNautical {
+		return FormatFloat(ConvertFromM(metersPerHour, "ft"), 0) + "ft/h"
+	}
+	return FormatFloat(metersPerHour, 0) + "m/h"
+}
+
 func FormatAltitude(altitude_m float64, unit_type UnitType) string {
 	if altitude_m < -20000 || altitude_m > 20000 {
 		return "n/a"
@@ -128,6 +199,24 @@ func FormatAltitude(altitude_m float64, unit_type UnitType) string {
 	return FormatFloat(ConvertFromM(altitude_m, "ft"), 0) + "ft"
 }
 
+// ParseHexColor parses "rrggbb" or "rrggbbaa" (with or without "#")
+func ParseHexColor(str string) (color.RGBA, error) {
+	str = strings.TrimPrefix(strings.TrimSpace(str), "#")
+	if len(str) == 6 {
+		str += "ff"
+	}
+	if len(str) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid color: %s", str)
+	}
+	n, err := strconv.ParseUint(str, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color: %s", str)
+	}
+	c := color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}
+	// color.RGBA is alpha premultiplied:
+	return color.RGBAModel.Convert(c).(color.RGBA), nil
+}
+
 func IsNanOrOnf(f float64) bool {
 	return math.IsNaN(f) || math.IsInf(f, 0)
 }
@@ -0,0 +1,80 @@
+package gpxcharts
+
+import (
+	"image/color"
+	"sort"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+var waypointColor = color.RGBA{0x30, 0x30, 0x80, 0xff}
+
+// trackPosition is a track point with its distance from start (without gaps between segments, as in
+// gpx.GetLocationsPositionsOnTrack) and its X in the chart
+type trackPosition struct {
+	point     gpx.GPXPoint
+	fromStart float64
+	x         float64
+	ok        bool
+}
+
+// waypointMarkers projects the waypoints onto the track, and returns vertical markers (with waypoint
+// names) at the waypoint positions on the elevation profile. A waypoint visited more times is shown on
+// every position. Waypoints farther than MaxWaypointDistance from the track are ignored.
+func (cs ChartService) waypointMarkers(params ChartParams, g gpx.GPX) []Marker {
+	if len(g.Waypoints) == 0 {
+		return nil
+	}
+
+	var (
+		positions []trackPosition
+		fromStart float64
+	)
+	xc := newXAxisCounter(params, g)
+	for _, track := range g.Tracks {
+		for _, segment := range track.Segments {
+			for n, pt := range segment.Points {
+				if n > 0 {
+					fromStart += pt.Distance2D(&segment.Points[n-1])
+				}
+				x, ok := xc.next(pt, n == 0)
+				positions = append(positions, trackPosition{point: pt, fromStart: fromStart, x: x, ok: ok})
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return nil
+	}
+
+	locations := make([]gpx.Location, len(g.Waypoints))
+	for n := range g.Waypoints {
+		locations[n] = &g.Waypoints[n]
+	}
+
+	var markers []Marker
+	for n, wptPositions := range g.GetLocationsPositionsOnTrack(len(positions), locations...) {
+		wpt := g.Waypoints[n]
+		for _, wptFromStart := range wptPositions {
+			i := sort.Search(len(positions), func(i int) bool { return positions[i].fromStart >= wptFromStart })
+			if i >= len(positions) {
+				i = len(positions) - 1
+			}
+			pos := positions[i]
+			if !pos.ok {
+				continue
+			}
+			if params.MaxWaypointDistance > 0 && pos.point.Distance2D(&wpt) > params.MaxWaypointDistance {
+				continue
+			}
+			markers = append(markers, Marker{
+				X:        pos.x,
+				Y:        pos.point.Elevation.Value(),
+				Color:    waypointColor,
+				Label:    wpt.Name,
+				Vertical: true,
+			})
+		}
+	}
+	sort.Slice(markers, func(i, j int) bool { return markers[i].X < markers[j].X })
+	return markers
+}
@@ -0,0 +1,64 @@
+package gpxcharts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestWaypointMarkers(t *testing.T) {
+	t.Parallel()
+
+	var segment gpx.GPXTrackSegment
+	for n := 0; n <= 100; n++ {
+		segment.Points = append(segment.Points, gpx.GPXPoint{Point: gpx.Point{Latitude: 0, Longitude: float64(n) * 0.001, Elevation: *gpx.NewNullableFloat64(float64(n))}})
+	}
+	g := gpx.GPX{
+		Tracks: []gpx.GPXTrack{{Segments: []gpx.GPXTrackSegment{segment}}},
+		Waypoints: []gpx.GPXPoint{
+			{Point: gpx.Point{Latitude: 0.0001, Longitude: 0.05}, Name: "near"},
+			{Point: gpx.Point{Latitude: 0.0007, Longitude: 0.02}, Name: "far"},
+			{Point: gpx.Point{Latitude: 0.1, Longitude: 0.02}, Name: "not on track"},
+		},
+	}
+
+	markers := chartService.waypointMarkers(ChartParams{}, g)
+	if assert.Equal(t, 2, len(markers)) {
+		assert.Equal(t, "far", markers[0].Label)
+		assert.InDelta(t, 0.02*oneDegree, markers[0].X, 10)
+		assert.Equal(t, 20., markers[0].Y)
+		assert.Equal(t, "near", markers[1].Label)
+		assert.InDelta(t, 0.05*oneDegree, markers[1].X, 10)
+		assert.Equal(t, 50., markers[1].Y)
+		for _, marker := range markers {
+			assert.True(t, marker.Vertical)
+		}
+	}
+
+	markers = chartService.waypointMarkers(ChartParams{MaxWaypointDistance: 50}, g)
+	if assert.Equal(t, 1, len(markers)) {
+		assert.Equal(t, "near", markers[0].Label)
+	}
+
+	params, err := chartService.elevationChartParams(ChartParams{}, g)
+	assert.Nil(t, err)
+	assert.Empty(t, params.Markers)
+	params, err = chartService.elevationChartParams(ChartParams{Waypoints: true}, g)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(params.Markers))
+}
+
+func TestElevationChartWithWaypoints(t *testing.T) {
+	t.Parallel()
+
+	g, err := gpx.ParseFile("../test_files/zbevnica.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	params := ChartParams{Width: 900, Height: 200, Waypoints: true, MaxWaypointDistance: 200, XAxis: Axis{Show: true}, YAxis: Axis{Show: true}, ChartMargin: Padding{Left: 40, Bottom: 20}}
+	prepared, err := chartService.elevationChartParams(params, *g)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, prepared.Markers)
+	assert.Equal(t, chartService.waypointMarkers(params, *g), prepared.Markers)
+}
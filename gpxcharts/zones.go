@@ -0,0 +1,197 @@
+package gpxcharts
+
+import (
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+)
+
+type ZoneMetric string
+
+const (
+	ZoneMetricSpeed     ZoneMetric = "speed"
+	ZoneMetricPace      ZoneMetric = "pace"
+	ZoneMetricExtension ZoneMetric = "ext"
+)
+
+func AllZoneMetrics() []ZoneMetric {
+	return []ZoneMetric{
+		ZoneMetricSpeed,
+		ZoneMetricPace,
+		ZoneMetricExtension,
+	}
+}
+
+// Zones are defined by boundaries, N boundaries define N+1 zones. Values on a boundary are in the
+// upper zone.
+type Zones struct {
+	Metric ZoneMetric `json:"metric"`
+	// Field is the extension name (for example "gpxtpx:hr") for ZoneMetricExtension
+	Field string `json:"field,omitempty"`
+	// Boundaries are in m/s (or SpeedUnit) for speed, in seconds per ChartParams.PaceUnit for
+	// pace, and extension values for extensions
+	Boundaries []float64 `json:"boundaries"`
+	// SpeedUnit is one of the SPEED_UNITS keys, default "mps"
+	SpeedUnit string `json:"speed_unit,omitempty"`
+	// Names (optional) for every zone
+	Names []string `json:"names,omitempty"`
+}
+
+func (z Zones) boundaries() ([]float64, error) {
+	res := append([]float64(nil), z.Boundaries...)
+	if z.Metric == ZoneMetricSpeed && z.SpeedUnit != "" {
+		unit, found := SPEED_UNITS[z.SpeedUnit]
+		if !found {
+			return nil, fmt.Errorf("invalid zones speed unit: %s", z.SpeedUnit)
+		}
+		for n := range res {
+			res[n] *= unit
+		}
+	}
+	sort.Float64s(res)
+	return res, nil
+}
+
+func (z Zones) zoneNames(boundaries []float64, formatter func(float64) string) []string {
+	res := make([]string, len(boundaries)+1)
+	for n := range res {
+		switch {
+		case n < len(z.Names) && z.Names[n] != "":
+			res[n] = z.Names[n]
+		case n == 0:
+			res[n] = "<" + formatter(boundaries[0])
+		case n == len(boundaries):
+			res[n] = ">" + formatter(boundaries[n-1])
+		default:
+			res[n] = formatter(boundaries[n-1]) + "-" + formatter(boundaries[n])
+		}
+	}
+	return res
+}
+
+// TimeInZones returns moving time (in seconds) spent in every zone. Stopped time is excluded, in the
+// same way as XAxisMovingTime.
+func (cs ChartService) TimeInZones(params ChartParams, g ExtendedGPX, zones Zones) ([]float64, error) {
+	params, g, err := params.selectExtendedSource(g)
+	if err != nil {
+		return nil, err
+	}
+	switch zones.Metric {
+	case ZoneMetricSpeed, ZoneMetricPace:
+	case ZoneMetricExtension:
+		if zones.Field == "" {
+			return nil, fmt.Errorf("no extension field for zones")
+		}
+	default:
+		return nil, fmt.Errorf("invalid zones metric: %s", zones.Metric)
+	}
+	boundaries, err := zones.boundaries()
+	if err != nil {
+		return nil, err
+	}
+	if len(boundaries) == 0 {
+		return nil, fmt.Errorf("no zone boundaries")
+	}
+
+	unitLength := params.PaceUnitOrDefault().Length()
+	params.XAxisMode = XAxisMovingTime
+	res := make([]float64, len(boundaries)+1)
+	var (
+		prevX   float64
+		hasPrev bool
+	)
+	forEachSpeed(g.GPX, newXAxisCounter(params, g.GPX), func(x, speed float64, trackNo, segmentNo, pointNo int) {
+		var value float64
+		switch zones.Metric {
+		case ZoneMetricSpeed:
+			value = speed
+		case ZoneMetricPace:
+			value = unitLength / speed
+		default:
+			v, found := g.Extensions(trackNo, segmentNo, pointNo).Get(zones.Field)
+			if !found {
+				prevX, hasPrev = x, true
+				return
+			}
+			value = v
+		}
+		if hasPrev && !IsNanOrOnf(value) {
+			res[bucketIndex(boundaries, value)] += x - prevX
+		}
+		prevX, hasPrev = x, true
+	})
+	return res, nil
+}
+
+func (cs ChartService) prepareZonesAxis(axis *Axis, names []string) {
+	axis.Formatter = func(f float64) string {
+		n := int(math.Round(f)) - 1
+		if 0 <= n && n < len(names) {
+			return names[n]
+		}
+		return ""
+	}
+	if axis.Labels == 0 {
+		axis.Labels = 1
+	}
+}
+
+// ZoneChart shows (horizontal bars) the moving time spent in every zone
+func (cs ChartService) ZoneChart(c context.Context, params ChartParams, g ExtendedGPX, zones Zones, output OutputExtension) ([]byte, error) {
+	times, err := cs.TimeInZones(params, g, zones)
+	if err != nil {
+		return nil, err
+	}
+
+	unitType := params.UnitTypeOrMetric()
+	paceUnit := params.PaceUnitOrDefault()
+	var formatter func(float64) string
+	switch zones.Metric {
+	case ZoneMetricSpeed:
+		formatter = func(f float64) string { return FormatSpeed(f, unitType, true) }
+	case ZoneMetricPace:
+		formatter = func(f float64) string { return FormatPace(f/paceUnit.Length(), paceUnit) }
+	default:
+		unit := extensionUnit(zones.Field)
+		formatter = func(f float64) string { return FormatFloat(f, 1) + unit }
+	}
+	boundaries, err := zones.boundaries()
+	if err != nil {
+		return nil, err
+	}
+	names := zones.zoneNames(boundaries, formatter)
+
+	var total, max float64
+	for _, t := range times {
+		total += t
+		max = math.Max(max, t)
+	}
+
+	params.Series = nil
+	params.Bars = nil
+	if total > 0 {
+		for n, t := range times {
+			k := float64(n) / float64(len(times)-1)
+			params.Bars = append(params.Bars, Bar{
+				MinX:       0,
+				MaxX:       t,
+				MinY:       float64(n) + 0.6,
+				MaxY:       float64(n) + 1.4,
+				Color:      color.RGBA{uint8(0x40 + 0xb0*k), uint8(0xa0 - 0x60*k), uint8(0xd0 - 0xa0*k), 0xff},
+				Label:      fmt.Sprintf("%d%%", int(math.Round(100*t/total))),
+				Horizontal: true,
+			})
+		}
+	}
+
+	// Chart padding is in chart units, which doesn't make sense for zones:
+	params.ChartPadding = Padding{}
+	params.MinY, params.MaxY = 0.5, float64(len(times))+0.5
+	// Space for labels right of bars:
+	params.MinX, params.MaxX = 0, 1.15*max
+	cs.prepareDurationAxis(&params.XAxis, max)
+	cs.prepareZonesAxis(&params.YAxis, names)
+	return cs.chart(c, params, output)
+}
@@ -0,0 +1,72 @@
+package gpxcharts
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeInZones(t *testing.T) {
+	t.Parallel()
+
+	g, err := ParseFile("../test_files/garmin.gpx")
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	movingTime := g.MovingData().MovingTime
+
+	for _, zones := range []Zones{
+		{Metric: ZoneMetricExtension, Field: "gpxtpx:hr", Boundaries: []float64{100, 120, 140, 155}},
+		{Metric: ZoneMetricSpeed, Boundaries: []float64{10, 20, 30}, SpeedUnit: "kmh"},
+		{Metric: ZoneMetricPace, Boundaries: []float64{120, 180, 240}},
+	} {
+		times, err := chartService.TimeInZones(ChartParams{}, *g, zones)
+		assert.Nil(t, err)
+		assert.Equal(t, len(zones.Boundaries)+1, len(times))
+		var total float64
+		for _, time := range times {
+			total += time
+		}
+		assert.True(t, total <= movingTime, zones.Metric)
+		assert.True(t, total > 0.9*movingTime, zones.Metric)
+	}
+
+	for _, zones := range []Zones{
+		{Metric: ZoneMetricSpeed},
+		{Metric: ZoneMetricExtension, Boundaries: []float64{100}},
+		{Metric: "hr", Boundaries: []float64{100}},
+		{Boundaries: []float64{100}},
+		{Metric: ZoneMetricSpeed, Boundaries: []float64{10}, SpeedUnit: "kph"},
+	} {
+		_, err = chartService.TimeInZones(ChartParams{}, *g, zones)
+		assert.NotNil(t, err, zones)
+		_, err = chartService.ZoneChart(context.Background(), ChartParams{Width: 600, Height: 200}, *g, zones, OutputPNG)
+		assert.NotNil(t, err, zones)
+	}
+}
+
+func TestTimeInZonesBoundaries(t *testing.T) {
+	t.Parallel()
+
+	// 5min per km, heart rate 120 for the first km and 140 for the second:
+	g := ExtendedGPX{GPX: pacedGPX(300, 300)}
+	var extensions []PointExtensions
+	for n := range g.Tracks[0].Segments[0].Points {
+		hr := 120.
+		if n > 100 {
+			hr = 140
+		}
+		extensions = append(extensions, PointExtensions{"gpxtpx:hr": hr})
+	}
+	g.TrackExtensions = [][][]PointExtensions{{extensions}}
+
+	// Values on a boundary are in the upper zone:
+	times, err := chartService.TimeInZones(ChartParams{}, g, Zones{Metric: ZoneMetricExtension, Field: "gpxtpx:hr", Boundaries: []float64{120, 140}})
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(times)) {
+		assert.Equal(t, 0.0, times[0])
+		assert.InDelta(t, 300, times[1], 5)
+		assert.InDelta(t, 300, times[2], 5)
+	}
+}